@@ -0,0 +1,168 @@
+package sieve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// BatchLoadFunc loads many keys with one call to the backend.
+// The keys that do not exist are left out of the returned map.
+type BatchLoadFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// BatchLoader collects the misses of concurrent callers and loads them with a single BatchLoadFunc call,
+// like a dataloader does for GraphQL resolvers.
+type BatchLoader[K comparable, V any] struct {
+	cache *Cache[K, V]
+	load  BatchLoadFunc[K, V]
+
+	maxBatch int
+	wait     time.Duration
+
+	mu sync.Mutex
+	// batch is the batch still collecting keys, nil if there is none
+	batch *batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	// ctx is the context of the first caller, without its cancellation
+	ctx  context.Context
+	keys []K
+	seen map[K]struct{}
+
+	once  sync.Once
+	timer *time.Timer
+	done  chan struct{}
+
+	values map[K]V
+	err    error
+}
+
+// NewBatchLoader returns a loader that fills c with the values returned by load.
+// A batch is dispatched when it holds maxBatch keys, or wait after its first key.
+func NewBatchLoader[K comparable, V any](c *Cache[K, V], load BatchLoadFunc[K, V], maxBatch int, wait time.Duration) *BatchLoader[K, V] {
+	return &BatchLoader[K, V]{
+		cache:    c,
+		load:     load,
+		maxBatch: max(maxBatch, 1),
+		wait:     wait,
+		mu:       sync.Mutex{},
+		batch:    nil,
+	}
+}
+
+// Load returns the value of the key from the cache, or from the next batch on a miss.
+// It returns ErrNotFound if the batch did not return the key.
+// With WithNegativeTTL on the cache, the keys missing from a batch are remembered with SetNotFound.
+func (l *BatchLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	switch v, res := l.cache.Lookup(key); res {
+	case Hit:
+		return v, nil
+	case Absent:
+		return v, ErrNotFound
+	case Miss:
+	}
+
+	b := l.add(ctx, key)
+
+	var zeroValue V
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		// the batch goes on for the other callers
+		return zeroValue, fmt.Errorf("sieve: batch load: %w", ctx.Err())
+	}
+
+	if b.err != nil {
+		return zeroValue, fmt.Errorf("sieve: batch load: %w", b.err)
+	}
+
+	v, ok := b.values[key]
+	if !ok {
+		return zeroValue, ErrNotFound
+	}
+
+	return v, nil
+}
+
+// add puts the key in the collecting batch, starting a new one if needed.
+func (l *BatchLoader[K, V]) add(ctx context.Context, key K) *batch[K, V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{
+			ctx:    context.WithoutCancel(ctx),
+			keys:   make([]K, 0, l.maxBatch),
+			seen:   make(map[K]struct{}, l.maxBatch),
+			once:   sync.Once{},
+			timer:  nil,
+			done:   make(chan struct{}),
+			values: nil,
+			err:    nil,
+		}
+
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(b) })
+
+		l.batch = b
+	}
+
+	if _, ok := b.seen[key]; !ok {
+		b.seen[key] = struct{}{}
+		b.keys = append(b.keys, key)
+	}
+
+	if len(b.keys) >= l.maxBatch {
+		// the batch is full, the next key starts a new one
+		l.batch = nil
+
+		b.timer.Stop()
+
+		go l.dispatch(b)
+	}
+
+	return b
+}
+
+// dispatch loads the keys of the batch and wakes up its callers, only the first call does something.
+func (l *BatchLoader[K, V]) dispatch(b *batch[K, V]) {
+	b.once.Do(func() {
+		l.mu.Lock()
+		if l.batch == b {
+			l.batch = nil
+		}
+		l.mu.Unlock()
+
+		start := time.Now()
+		values, err := l.load(b.ctx, b.keys)
+		l.cache.stats.loads.observe(time.Since(start))
+		if err == nil {
+			l.cache.SetMany(values)
+			l.fillNotFound(b.keys, values)
+		} else {
+			l.cache.logger.log("sieve: batch load failed", slog.Int("keys", len(b.keys)), slog.Any("error", err))
+		}
+
+		b.values = values
+		b.err = err
+
+		close(b.done)
+	})
+}
+
+// fillNotFound remembers the keys missing from values, if the cache has negative caching.
+func (l *BatchLoader[K, V]) fillNotFound(keys []K, values map[K]V) {
+	if l.cache.negativeTTL <= 0 {
+		return
+	}
+
+	for _, k := range keys {
+		if _, ok := values[k]; !ok {
+			l.cache.SetNotFound(k)
+		}
+	}
+}
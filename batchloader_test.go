@@ -0,0 +1,197 @@
+package sieve_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// recordingLoad returns the keys that are even, and records every batch.
+type recordingLoad struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+}
+
+func (r *recordingLoad) load(_ context.Context, keys []int) (map[int]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.batches = append(r.batches, slices.Clone(keys))
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	values := make(map[int]int)
+
+	for _, k := range keys {
+		if k%2 == 0 {
+			values[k] = k * 10
+		}
+	}
+
+	return values, nil
+}
+
+func (r *recordingLoad) calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.batches)
+}
+
+func TestBatchLoaderCoalesces(t *testing.T) {
+	rec := &recordingLoad{}
+	c := sieve.New[int, int](100)
+	l := sieve.NewBatchLoader(c, rec.load, 100, 20*time.Millisecond)
+
+	var wg sync.WaitGroup
+
+	for i := range 10 {
+		for range 3 {
+			wg.Go(func() {
+				v, err := l.Load(context.Background(), i)
+
+				switch {
+				case i%2 == 0 && (err != nil || v != i*10):
+					t.Errorf("expected %d for key %d, got %d with %v", i*10, i, v, err)
+				case i%2 == 1 && !errors.Is(err, sieve.ErrNotFound):
+					t.Errorf("expected ErrNotFound for key %d, got %v", i, err)
+				}
+			})
+		}
+	}
+
+	wg.Wait()
+
+	if rec.calls() != 1 {
+		t.Fatalf("expected 1 batch, got %d", rec.calls())
+	}
+
+	// every key once
+	if len(rec.batches[0]) != 10 {
+		t.Errorf("expected 10 keys in the batch, got %v", rec.batches[0])
+	}
+
+	if c.Len() != 5 {
+		t.Errorf("expected 5 keys cached, got %d", c.Len())
+	}
+
+	// now it is a hit
+	if v, err := l.Load(context.Background(), 4); err != nil || v != 40 {
+		t.Errorf("expected 40, got %d with %v", v, err)
+	}
+
+	if rec.calls() != 1 {
+		t.Errorf("expected the hit to skip the loader, got %d batches", rec.calls())
+	}
+}
+
+func TestBatchLoaderMaxBatch(t *testing.T) {
+	rec := &recordingLoad{}
+	l := sieve.NewBatchLoader(sieve.New[int, int](100), rec.load, 4, time.Hour)
+
+	var wg sync.WaitGroup
+
+	// with an hour of wait, only full batches can return
+	for i := range 8 {
+		wg.Go(func() {
+			l.Load(context.Background(), i)
+		})
+	}
+
+	wg.Wait()
+
+	if rec.calls() != 2 {
+		t.Errorf("expected 2 batches, got %d", rec.calls())
+	}
+
+	for _, b := range rec.batches {
+		if len(b) != 4 {
+			t.Errorf("expected batches of 4 keys, got %v", b)
+		}
+	}
+}
+
+func TestBatchLoaderError(t *testing.T) {
+	rec := &recordingLoad{err: errBackend}
+	c := sieve.New[int, int](100)
+	l := sieve.NewBatchLoader(c, rec.load, 10, time.Millisecond)
+
+	if _, err := l.Load(context.Background(), 2); !errors.Is(err, errBackend) {
+		t.Errorf("expected the backend error, got %v", err)
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("expected nothing cached, got %d", c.Len())
+	}
+}
+
+func TestBatchLoaderCancel(t *testing.T) {
+	rec := &recordingLoad{}
+	l := sieve.NewBatchLoader(sieve.New[int, int](100), rec.load, 10, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+
+	go func() {
+		_, err := l.Load(ctx, 2)
+		done <- err
+	}()
+
+	go func() {
+		// same batch, not canceled
+		_, err := l.Load(context.Background(), 4)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	errs := []error{<-done, <-done}
+
+	canceled := 0
+
+	for _, err := range errs {
+		if errors.Is(err, context.Canceled) {
+			canceled++
+		} else if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+
+	if canceled != 1 {
+		t.Errorf("expected one canceled caller, got %v", errs)
+	}
+
+	if rec.calls() != 1 {
+		t.Errorf("expected the batch to be loaded anyway, got %d batches", rec.calls())
+	}
+}
+
+func TestBatchLoaderNegativeCaching(t *testing.T) {
+	rec := &recordingLoad{}
+	c := sieve.New[int, int](100).WithNegativeTTL(time.Minute)
+	l := sieve.NewBatchLoader(c, rec.load, 10, time.Millisecond)
+
+	for range 3 {
+		if _, err := l.Load(context.Background(), 3); !errors.Is(err, sieve.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	}
+
+	if rec.calls() != 1 {
+		t.Errorf("expected 1 batch, got %d", rec.calls())
+	}
+
+	if _, res := c.Lookup(3); res != sieve.Absent {
+		t.Errorf("expected key 3 to be absent, got %s", res)
+	}
+}
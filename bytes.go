@@ -0,0 +1,284 @@
+package sieve
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sync"
+)
+
+// ErrTooLarge is returned by BytesCache.Set when the value does not fit in the largest size class or in the budget.
+var ErrTooLarge = errors.New("sieve: value too large")
+
+const (
+	// slabSize is the largest size of the arrays the values are copied into, and of the largest size class.
+	slabSize = 1 << 20
+
+	minClass = 64
+)
+
+// classes are the slot sizes, growing by 1.25 like memcached, so that a value wastes at most a fifth of its slot.
+var classes = sizeClasses()
+
+func sizeClasses() []int {
+	var sizes []int
+
+	for size := minClass; size < slabSize; size = (size*5/4 + 7) &^ 7 {
+		sizes = append(sizes, size)
+	}
+
+	return append(sizes, slabSize)
+}
+
+// classOf returns the smallest size class that holds n bytes.
+func classOf(n int) int {
+	for c, size := range classes {
+		if n <= size {
+			return c
+		}
+	}
+
+	return -1
+}
+
+// slot locates a value in the slabs of its class.
+type slot struct {
+	class uint8
+	index int32
+	len   int32
+}
+
+// slabClass holds the slabs of one size class and its free slots.
+type slabClass struct {
+	// size is the size of a slot, slabSize the one of a slab holding perSlab of them.
+	size     int
+	slabSize int
+	perSlab  int32
+
+	// slabs holds nil where a slab was released, used the slots in use in each slab.
+	slabs [][]byte
+	used  []int32
+	free  []int32
+}
+
+// BytesCache is a SIEVE cache of byte slices bounded by the bytes it holds rather than by the entries.
+// The values are copied into slabs cut in slots of a few size classes, and the slot of an evicted
+// value is reused by the next one of the same class, so the GC sees a few big arrays instead of a slice per value.
+type BytesCache struct {
+	c *Cache[string, slot]
+
+	classes []slabClass
+
+	// used is the size of the slabs, budget its limit.
+	used   int64
+	budget int64
+
+	mu sync.Locker
+}
+
+// NewBytes returns a new BytesCache whose slabs take at most budget bytes.
+// A slab is a sixteenth of the budget, up to 1 MiB, or a single slot for the classes larger than that,
+// and it is released once its last value leaves.
+// A value uses the slot of its size class, up to a quarter more than its length.
+// When its class has no free slot and a new slab does not fit, the hand evicts a value of the same class,
+// or any value until a slab is released if the class has none.
+// If the budget is less than or equal to zero, it panics.
+func NewBytes(budget int64) *BytesCache {
+	if budget <= 0 {
+		panic("sieve: budget must be greater than zero")
+	}
+
+	s := &BytesCache{
+		c:       nil,
+		classes: newSlabClasses(budget),
+		used:    0,
+		budget:  budget,
+		mu:      &sync.Mutex{},
+	}
+
+	// the budget bounds the entries, the count only keeps the inner sieve in range
+	size := int32(min(budget/minClass, math.MaxInt32))
+	s.c = NewSingleThread[string, slot](max(size, 1)).OnEvict(func(_ string, v slot, _ EvictReason) {
+		s.release(v)
+	})
+
+	return s
+}
+
+// slabsPerBudget is how many slabs of the small classes fit in the budget,
+// so that the classes in use share it instead of one slab taking all of it.
+const slabsPerBudget = 16
+
+func newSlabClasses(budget int64) []slabClass {
+	sc := make([]slabClass, len(classes))
+
+	for c, size := range classes {
+		perSlab := max(int(min(budget/slabsPerBudget, slabSize))/size, 1)
+
+		sc[c] = slabClass{
+			size:     size,
+			slabSize: perSlab * size,
+			perSlab:  int32(perSlab),
+			slabs:    nil,
+			used:     nil,
+			free:     nil,
+		}
+	}
+
+	return sc
+}
+
+// Len returns the number of values in the cache.
+func (s *BytesCache) Len() int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.c.Len()
+}
+
+// Bytes returns the size of the slabs, that the budget bounds.
+func (s *BytesCache) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.used
+}
+
+// Get appends the value of the key to dst and returns it, marking the key as visited.
+// The value is copied because its slot is reused once the key leaves the cache.
+func (s *BytesCache) Get(key string, dst []byte) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.c.Get(key)
+	if !ok {
+		return dst, false
+	}
+
+	return append(dst, s.bytes(v)...), true
+}
+
+// Set copies value into the cache, evicting with SIEVE until its slot fits in the budget.
+// It returns ErrTooLarge if the value is larger than the largest class or than the budget.
+func (s *BytesCache) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	class := classOf(len(value))
+	if class < 0 || int64(classes[class]) > s.budget {
+		return ErrTooLarge
+	}
+
+	c := &s.classes[class]
+
+	sameClass := func(n *node[string, slot]) bool { return int(n.value.class) == class }
+
+	// the old value of the key is counted until it is replaced, it may even be the victim
+	for len(c.free) == 0 && s.used+int64(c.slabSize) > s.budget && s.c.Len() > 0 {
+		if s.slabs(c) > 0 {
+			s.c.evictWhere(sameClass)
+		} else {
+			s.c.evictNode()
+		}
+	}
+
+	v := s.alloc(class, len(value))
+	copy(s.bytes(v), value)
+
+	old, replaced := s.c.Peek(key)
+
+	s.c.Set(key, v)
+
+	if replaced {
+		s.release(old)
+	}
+
+	return nil
+}
+
+// Delete removes the key and frees its slot.
+func (s *BytesCache) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.c.Delete(key)
+}
+
+// Flush removes all values and drops the slabs.
+func (s *BytesCache) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.c.Flush()
+
+	s.classes = newSlabClasses(s.budget)
+	s.used = 0
+}
+
+// bytes returns the part of the slab holding v.
+func (s *BytesCache) bytes(v slot) []byte {
+	c := &s.classes[v.class]
+	slab := c.slabs[v.index/c.perSlab]
+	off := int(v.index%c.perSlab) * c.size
+
+	return slab[off : off+int(v.len) : off+c.size]
+}
+
+// slabs returns the number of slabs of c.
+func (s *BytesCache) slabs(c *slabClass) int {
+	n := 0
+
+	for _, slab := range c.slabs {
+		if slab != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// alloc takes a free slot of the class, cutting a new slab when there is none.
+func (s *BytesCache) alloc(class, n int) slot {
+	c := &s.classes[class]
+
+	if len(c.free) == 0 {
+		// in the place of a released slab if there is one
+		i := slices.IndexFunc(c.slabs, func(slab []byte) bool { return slab == nil })
+		if i < 0 {
+			i = len(c.slabs)
+			c.slabs = append(c.slabs, nil)
+			c.used = append(c.used, 0)
+		}
+
+		c.slabs[i] = make([]byte, c.slabSize)
+		s.used += int64(c.slabSize)
+
+		// the lowest index is taken first
+		first := int32(i) * c.perSlab
+		for j := first + c.perSlab - 1; j >= first; j-- {
+			c.free = append(c.free, j)
+		}
+	}
+
+	index := c.free[len(c.free)-1]
+	c.free = c.free[:len(c.free)-1]
+	c.used[index/c.perSlab]++
+
+	return slot{class: uint8(class), index: index, len: int32(n)}
+}
+
+// release gives back the slot of a value that left the cache, and its slab once empty.
+func (s *BytesCache) release(v slot) {
+	c := &s.classes[v.class]
+	c.free = append(c.free, v.index)
+
+	i := v.index / c.perSlab
+
+	if c.used[i]--; c.used[i] > 0 {
+		return
+	}
+
+	c.slabs[i] = nil
+	c.free = slices.DeleteFunc(c.free, func(j int32) bool { return j/c.perSlab == i })
+	s.used -= int64(c.slabSize)
+}
@@ -0,0 +1,236 @@
+package sieve
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestSizeClasses(t *testing.T) {
+	for c := 1; c < len(classes); c++ {
+		if classes[c] <= classes[c-1] || classes[c]%8 != 0 {
+			t.Fatalf("expected growing classes aligned to 8, got %d after %d", classes[c], classes[c-1])
+		}
+	}
+
+	if classes[len(classes)-1] != slabSize {
+		t.Errorf("expected the largest class to be a slab, got %d", classes[len(classes)-1])
+	}
+
+	if c := classOf(100); classes[c] < 100 || classes[c-1] >= 100 {
+		t.Errorf("expected the smallest class holding 100 bytes, got %d", classes[c])
+	}
+}
+
+func TestBytesGet(t *testing.T) {
+	s := NewBytes(1 << 20)
+
+	if err := s.Set("a", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok := s.Get("a", []byte("say "))
+	if !ok || string(v) != "say hello" {
+		t.Errorf("expected say hello, got %q, %v", v, ok)
+	}
+
+	if _, ok := s.Get("b", nil); ok {
+		t.Errorf("expected b to be missing")
+	}
+
+	// the slot is reused by the next value, the slice returned before must not change
+	s.Delete("a")
+
+	if err := s.Set("b", []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(v) != "say hello" {
+		t.Errorf("expected the returned value to be a copy, got %q", v)
+	}
+}
+
+func TestBytesBudget(t *testing.T) {
+	s := NewBytes(10 * minClass)
+
+	for i := range 100 {
+		if err := s.Set(strconv.Itoa(i), []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Bytes() > 10*minClass {
+			t.Fatalf("expected at most %d bytes, got %d", 10*minClass, s.Bytes())
+		}
+	}
+
+	if s.Len() != 10 {
+		t.Errorf("expected 10 values, got %d", s.Len())
+	}
+
+	// a sixteenth of the budget is less than a slot, so each slab is one slot
+	if n := s.slabs(&s.classes[0]); n != 10 {
+		t.Errorf("expected 10 slabs, got %d", n)
+	}
+}
+
+func TestBytesMixedSizesBudget(t *testing.T) {
+	const budget = 64 << 10
+
+	s := NewBytes(budget)
+
+	for i := range 2000 {
+		// every class up to 16 KiB
+		value := make([]byte, (i*37)%(16<<10)+1)
+
+		if err := s.Set(strconv.Itoa(i), value); err != nil {
+			t.Fatal(err)
+		}
+
+		allocated := int64(0)
+		for c := range s.classes {
+			for _, slab := range s.classes[c].slabs {
+				allocated += int64(len(slab))
+			}
+		}
+
+		if allocated != s.Bytes() || allocated > budget {
+			t.Fatalf("expected at most %d bytes of slabs, got %d counted as %d", budget, allocated, s.Bytes())
+		}
+	}
+
+	if err := s.c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBytesEvictsWithinClass(t *testing.T) {
+	// 16 slabs of 4 slots of the smallest class
+	s := NewBytes(slabsPerBudget * 4 * minClass)
+
+	small := []byte("s")
+	for i := range 64 {
+		_ = s.Set(strconv.Itoa(i), small)
+	}
+
+	// no slab of its class yet, the hand evicts until a slab is released
+	big := bytes.Repeat([]byte{1}, classes[1])
+	if err := s.Set("big", big); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Len() != 61 {
+		t.Errorf("expected the 4 values of a slab evicted, got %d values", s.Len())
+	}
+
+	// the small values make room among themselves
+	_ = s.Set("small", small)
+
+	if _, ok := s.Get("big", nil); !ok {
+		t.Errorf("expected the value of another class to be kept")
+	}
+
+	if s.Len() != 61 {
+		t.Errorf("expected one small value evicted for another, got %d values", s.Len())
+	}
+
+	if s.Bytes() > slabsPerBudget*4*minClass {
+		t.Errorf("expected the slabs to fit in the budget, got %d", s.Bytes())
+	}
+}
+
+func TestBytesEvictsWithSieve(t *testing.T) {
+	s := NewBytes(3 * minClass)
+
+	for _, k := range []string{"a", "b", "c"} {
+		_ = s.Set(k, []byte(k))
+	}
+
+	s.Get("a", nil)
+
+	// a value of two slots needs two victims, b and c, a was visited
+	big := bytes.Repeat([]byte{1}, minClass+1)
+	if err := s.Set("d", big); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.Get("a", nil); !ok {
+		t.Errorf("expected a to be kept")
+	}
+
+	for _, k := range []string{"b", "c"} {
+		if _, ok := s.Get(k, nil); ok {
+			t.Errorf("expected %s to be evicted", k)
+		}
+	}
+
+	if v, _ := s.Get("d", nil); !bytes.Equal(v, big) {
+		t.Errorf("expected d to be the big value")
+	}
+
+	if err := s.c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBytesReplace(t *testing.T) {
+	s := NewBytes(1 << 20)
+
+	_ = s.Set("a", []byte("small"))
+
+	// the new value moves to a larger class, the old slot is freed
+	_ = s.Set("a", bytes.Repeat([]byte{1}, 1000))
+
+	// the slab of the small value was released with it
+	if got, expected := s.Bytes(), int64(s.classes[classOf(1000)].slabSize); got != expected {
+		t.Errorf("expected %d bytes, got %d", expected, got)
+	}
+
+	if s.slabs(&s.classes[0]) != 0 || len(s.classes[0].free) != 0 {
+		t.Errorf("expected the small slab to be released")
+	}
+
+	if !s.Delete("a") || s.Bytes() != 0 || s.Len() != 0 {
+		t.Errorf("expected an empty cache after the delete, got %d bytes", s.Bytes())
+	}
+}
+
+func TestBytesTooLarge(t *testing.T) {
+	s := NewBytes(1 << 30)
+
+	if err := s.Set("a", make([]byte, slabSize+1)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	s = NewBytes(100)
+
+	if err := s.Set("a", make([]byte, 90)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge over the budget, got %v", err)
+	}
+}
+
+func TestBytesFlush(t *testing.T) {
+	s := NewBytes(1 << 20)
+
+	_ = s.Set("a", []byte("a"))
+
+	s.Flush()
+
+	if s.Len() != 0 || s.Bytes() != 0 || len(s.classes[0].slabs) != 0 {
+		t.Errorf("expected an empty cache without slabs")
+	}
+
+	if _, ok := s.Get("a", nil); ok {
+		t.Errorf("expected a to be flushed")
+	}
+}
+
+func TestNewBytesPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "sieve: budget must be greater than zero" {
+			t.Errorf("expected a panic, got %v", r)
+		}
+	}()
+
+	NewBytes(0)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// client is a minimal RESP client, used by the `cli` subcommand and the tests.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func dial(network, address string) (*client, error) {
+	conn, err := net.DialTimeout(network, address, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("sieved: dial %s %s: %w", network, address, err)
+	}
+
+	return &client{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}, nil
+}
+
+// do sends a command and waits for its reply.
+func (c *client) do(args ...string) (any, error) {
+	writeArray(c.w, args)
+
+	if err := c.w.Flush(); err != nil {
+		return nil, fmt.Errorf("sieved: write: %w", err)
+	}
+
+	reply, err := readReply(c.r)
+	if err != nil {
+		return nil, fmt.Errorf("sieved: read: %w", err)
+	}
+
+	return reply, nil
+}
+
+func (c *client) close() error {
+	return c.conn.Close()
+}
+
+// printReply writes the reply the same way redis-cli does.
+func printReply(w io.Writer, reply any, indent string) {
+	switch v := reply.(type) {
+	case nil:
+		fmt.Fprintln(w, "(nil)")
+	case string:
+		fmt.Fprintln(w, v)
+	case respError:
+		fmt.Fprintf(w, "(error) %s\n", string(v))
+	case int64:
+		fmt.Fprintf(w, "(integer) %d\n", v)
+	case []byte:
+		if strings.Contains(string(v), "\n") {
+			fmt.Fprint(w, string(v))
+		} else {
+			fmt.Fprintf(w, "%q\n", v)
+		}
+	case []any:
+		if len(v) == 0 {
+			fmt.Fprintln(w, "(empty array)")
+		}
+
+		for i, item := range v {
+			fmt.Fprintf(w, "%s%d) ", indent, i+1)
+			printReply(w, item, indent+"   ")
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Command sieved serves a sieve cache over a subset of the Redis protocol.
+//
+// Supported commands: GET, SET (with EX/PX), DEL, EXISTS, DBSIZE, FLUSHALL, INFO, PING and QUIT.
+//
+// Start the daemon:
+//
+//	sieved -addr 127.0.0.1:6379 -unix /tmp/sieved.sock -capacity 100000
+//
+// Talk to it with the built-in client:
+//
+//	sieved cli -addr 127.0.0.1:6379 SET greeting hello EX 60
+//	sieved cli -unix /tmp/sieved.sock INFO
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/guerinoni/sieve"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cli" {
+		os.Exit(runClient(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	os.Exit(runServer(os.Args[1:], os.Stderr))
+}
+
+func runServer(args []string, stderr io.Writer) int {
+	fset := flag.NewFlagSet("sieved", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+
+	addr := fset.String("addr", "127.0.0.1:6379", "TCP address to listen on, empty to disable")
+	unix := fset.String("unix", "", "unix socket path to listen on, empty to disable")
+	capacity := fset.Int("capacity", 100_000, "maximum number of keys")
+
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+
+	if *addr == "" && *unix == "" {
+		fmt.Fprintln(stderr, "sieved: at least one of -addr and -unix is required")
+
+		return 2
+	}
+
+	if *capacity <= 0 || *capacity > 1<<31-1 {
+		fmt.Fprintln(stderr, "sieved: -capacity must be between 1 and 2147483647")
+
+		return 2
+	}
+
+	srv := newServer(sieve.New[string, []byte](int32(*capacity)))
+
+	var listeners []net.Listener
+
+	if *addr != "" {
+		l, err := net.Listen("tcp", *addr)
+		if err != nil {
+			fmt.Fprintln(stderr, "sieved:", err)
+
+			return 1
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	if *unix != "" {
+		// a socket left behind by a previous run prevents listening
+		if err := os.Remove(*unix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintln(stderr, "sieved:", err)
+
+			return 1
+		}
+
+		l, err := net.Listen("unix", *unix)
+		if err != nil {
+			fmt.Fprintln(stderr, "sieved:", err)
+
+			return 1
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	errs := make(chan error, len(listeners))
+
+	for _, l := range listeners {
+		fmt.Fprintf(stderr, "sieved: listening on %s %s\n", l.Addr().Network(), l.Addr())
+
+		go func() {
+			errs <- srv.serve(l)
+		}()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	code := 0
+
+	select {
+	case <-sig:
+	case err := <-errs:
+		fmt.Fprintln(stderr, "sieved:", err)
+
+		code = 1
+	}
+
+	srv.close()
+
+	return code
+}
+
+func runClient(args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("sieved cli", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+
+	addr := fset.String("addr", "127.0.0.1:6379", "TCP address of the server")
+	unix := fset.String("unix", "", "unix socket path of the server, it wins over -addr")
+
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+
+	if fset.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: sieved cli [-addr host:port | -unix path] COMMAND [ARG...]")
+
+		return 2
+	}
+
+	network, address := "tcp", *addr
+	if *unix != "" {
+		network, address = "unix", *unix
+	}
+
+	c, err := dial(network, address)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return 1
+	}
+	defer c.close()
+
+	reply, err := c.do(fset.Args()...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return 1
+	}
+
+	printReply(stdout, reply, "")
+
+	if _, ok := reply.(respError); ok {
+		return 1
+	}
+
+	return 0
+}
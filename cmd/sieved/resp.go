@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxBulkLen  = 512 << 20
+	maxArrayLen = 1 << 20
+)
+
+var errProtocol = errors.New("Protocol error") //nolint: staticcheck // this is the message Redis uses
+
+// respError is an error reply, as sent by the server.
+type respError string
+
+func (e respError) Error() string {
+	return string(e)
+}
+
+// readCommand reads a command either as an array of bulk strings or as an inline command.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		// inline command, as typed in a telnet session
+		fields := strings.Fields(string(line))
+
+		args := make([][]byte, 0, len(fields))
+		for _, f := range fields {
+			args = append(args, []byte(f))
+		}
+
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArrayLen {
+		return nil, errProtocol
+	}
+
+	args := make([][]byte, 0, max(n, 0))
+
+	for range n {
+		arg, err := readBulk(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if arg == nil {
+			return nil, errProtocol
+		}
+
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// readBulk reads a bulk string, returning nil for the null bulk string.
+func readBulk(r *bufio.Reader) ([]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '$' {
+		return nil, errProtocol
+	}
+
+	return readBulkBody(r, line)
+}
+
+func readBulkBody(r *bufio.Reader, header []byte) ([]byte, error) {
+	n, err := strconv.Atoi(string(header[1:]))
+	if err != nil || n > maxBulkLen || n < -1 {
+		return nil, errProtocol
+	}
+
+	if n == -1 {
+		return nil, nil
+	}
+
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return nil, errProtocol
+	}
+
+	return buf[:n], nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, errProtocol
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+
+	return line, nil
+}
+
+// readReply reads any reply: string for simple strings, respError, int64,
+// []byte or nil for bulk strings and []any for arrays.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 {
+		return nil, errProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return respError(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return nil, errProtocol
+		}
+
+		return n, nil
+	case '$':
+		b, err := readBulkBody(r, line)
+		if err != nil || b == nil {
+			return nil, err
+		}
+
+		return b, nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n > maxArrayLen {
+			return nil, errProtocol
+		}
+
+		if n < 0 {
+			return nil, nil
+		}
+
+		items := make([]any, 0, n)
+
+		for range n {
+			item, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, item)
+		}
+
+		return items, nil
+	default:
+		return nil, errProtocol
+	}
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+")
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-")
+	w.WriteString(msg)
+	w.WriteString("\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	fmt.Fprintf(w, ":%d\r\n", n)
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+
+		return
+	}
+
+	fmt.Fprintf(w, "$%d\r\n", len(b))
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func writeArray(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+
+	for _, a := range args {
+		writeBulk(w, []byte(a))
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// server answers a subset of the Redis protocol from a sieve.
+type server struct {
+	cache *sieve.Cache[string, []byte]
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func newServer(cache *sieve.Cache[string, []byte]) *server {
+	return &server{
+		cache:     cache,
+		mu:        sync.Mutex{},
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		closed:    false,
+		wg:        sync.WaitGroup{},
+	}
+}
+
+// serve accepts connections on l until the server is closed.
+func (s *server) serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return net.ErrClosed
+	}
+
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return nil
+			}
+
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+
+			return nil
+		}
+
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+
+			s.handle(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// close stops the listeners and drops the open connections.
+func (s *server) close() {
+	s.mu.Lock()
+	s.closed = true
+
+	for l := range s.listeners {
+		l.Close()
+	}
+
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				writeError(w, "ERR "+err.Error())
+				w.Flush()
+			}
+
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.exec(w, args)
+
+		// reply to pipelined commands in one write
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+
+		if quit {
+			return
+		}
+	}
+}
+
+// exec runs a command and writes its reply, it returns true if the connection must be closed.
+func (s *server) exec(w *bufio.Writer, args [][]byte) bool {
+	raw := string(args[0])
+	name := strings.ToUpper(raw)
+	args = args[1:]
+
+	switch name {
+	case "PING":
+		if len(args) > 0 {
+			writeBulk(w, args[0])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "QUIT":
+		writeSimple(w, "OK")
+
+		return true
+	case "GET":
+		if len(args) != 1 {
+			writeArity(w, name)
+
+			break
+		}
+
+		// a miss returns a nil slice, that is the null bulk string
+		v, _ := s.cache.Get(string(args[0]))
+
+		writeBulk(w, v)
+	case "SET":
+		s.set(w, args)
+	case "DEL", "EXISTS":
+		if len(args) == 0 {
+			writeArity(w, name)
+
+			break
+		}
+
+		var n int64
+
+		for _, k := range args {
+			if (name == "DEL" && s.cache.Delete(string(k))) || (name == "EXISTS" && s.cache.Contains(string(k))) {
+				n++
+			}
+		}
+
+		writeInt(w, n)
+	case "DBSIZE":
+		writeInt(w, int64(s.cache.Len()))
+	case "FLUSHALL", "FLUSHDB":
+		s.cache.Flush()
+		writeSimple(w, "OK")
+	case "INFO":
+		writeBulk(w, []byte(info(s.cache.Stats())))
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", raw))
+	}
+
+	return false
+}
+
+// set handles SET key value [EX seconds | PX milliseconds].
+func (s *server) set(w *bufio.Writer, args [][]byte) {
+	if len(args) != 2 && len(args) != 4 {
+		if len(args) < 2 {
+			writeArity(w, "SET")
+		} else {
+			writeError(w, "ERR syntax error")
+		}
+
+		return
+	}
+
+	var ttl time.Duration
+
+	if len(args) == 4 {
+		n, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, "ERR invalid expire time in 'set' command")
+
+			return
+		}
+
+		var unit time.Duration
+
+		switch strings.ToUpper(string(args[2])) {
+		case "EX":
+			unit = time.Second
+		case "PX":
+			unit = time.Millisecond
+		default:
+			writeError(w, "ERR syntax error")
+
+			return
+		}
+
+		// a longer expiration overflows the duration
+		if n > math.MaxInt64/int64(unit) {
+			writeError(w, "ERR invalid expire time in 'set' command")
+
+			return
+		}
+
+		ttl = time.Duration(n) * unit
+	}
+
+	s.cache.SetWithTTL(string(args[0]), args[1], ttl)
+	writeSimple(w, "OK")
+}
+
+func writeArity(w *bufio.Writer, name string) {
+	writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+// info renders the stats in the INFO format.
+func info(st sieve.Stats) string {
+	var b strings.Builder
+
+	ratio := 0.0
+	if total := st.Hits + st.Misses; total > 0 {
+		ratio = float64(st.Hits) / float64(total)
+	}
+
+	b.WriteString("# Stats\r\n")
+	fmt.Fprintf(&b, "keyspace_hits:%d\r\n", st.Hits)
+	fmt.Fprintf(&b, "keyspace_misses:%d\r\n", st.Misses)
+	fmt.Fprintf(&b, "hit_ratio:%.4f\r\n", ratio)
+	fmt.Fprintf(&b, "evicted_keys:%d\r\n", st.Evictions)
+	fmt.Fprintf(&b, "expired_keys:%d\r\n", st.Expirations)
+	b.WriteString("\r\n# Keyspace\r\n")
+	fmt.Fprintf(&b, "keys:%d\r\n", st.Len)
+	fmt.Fprintf(&b, "capacity:%d\r\n", st.Capacity)
+
+	return b.String()
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// startServer serves a fresh cache on a loopback TCP listener and on a unix socket.
+func startServer(t *testing.T, capacity int32) (*server, string, string) {
+	t.Helper()
+
+	srv := newServer(sieve.New[string, []byte](capacity))
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+
+	// t.TempDir can exceed the maximum length of a socket path
+	dir, err := os.MkdirTemp("", "sieved")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "s.sock")
+
+	unix, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+
+	for _, l := range []net.Listener{tcp, unix} {
+		go srv.serve(l)
+	}
+
+	t.Cleanup(srv.close)
+
+	return srv, tcp.Addr().String(), path
+}
+
+func mustDial(t *testing.T, network, address string) *client {
+	t.Helper()
+
+	c, err := dial(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.close() })
+
+	return c
+}
+
+func expectReply(t *testing.T, c *client, expected any, args ...string) {
+	t.Helper()
+
+	got, err := c.do(args...)
+	if err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("%v: expected %#v, got %#v", args, expected, got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	_, addr, _ := startServer(t, 10)
+	c := mustDial(t, "tcp", addr)
+
+	expectReply(t, c, "PONG", "PING")
+	expectReply(t, c, nil, "GET", "a")
+	expectReply(t, c, "OK", "SET", "a", "1")
+	expectReply(t, c, []byte("1"), "GET", "a")
+	expectReply(t, c, "OK", "set", "b", "")
+	expectReply(t, c, []byte(""), "GET", "b")
+	expectReply(t, c, int64(2), "EXISTS", "a", "b", "c")
+	expectReply(t, c, int64(2), "DBSIZE")
+	expectReply(t, c, int64(1), "DEL", "a", "c")
+	expectReply(t, c, nil, "GET", "a")
+	expectReply(t, c, "OK", "FLUSHALL")
+	expectReply(t, c, int64(0), "DBSIZE")
+}
+
+func TestErrors(t *testing.T) {
+	_, addr, _ := startServer(t, 10)
+	c := mustDial(t, "tcp", addr)
+
+	expectReply(t, c, respError("ERR unknown command 'NOPE'"), "NOPE")
+	expectReply(t, c, respError("ERR wrong number of arguments for 'get' command"), "GET")
+	expectReply(t, c, respError("ERR wrong number of arguments for 'set' command"), "SET", "a")
+	expectReply(t, c, respError("ERR syntax error"), "SET", "a", "1", "EX")
+	expectReply(t, c, respError("ERR syntax error"), "SET", "a", "1", "KEEPTTL", "1")
+	expectReply(t, c, respError("ERR invalid expire time in 'set' command"), "SET", "a", "1", "EX", "0")
+	expectReply(t, c, respError("ERR invalid expire time in 'set' command"), "SET", "a", "1", "EX", "9223372037")
+	expectReply(t, c, respError("ERR invalid expire time in 'set' command"), "SET", "a", "1", "PX", "9223372036855")
+
+	// the connection is still usable
+	expectReply(t, c, "PONG", "PING")
+}
+
+func TestExpire(t *testing.T) {
+	_, addr, _ := startServer(t, 10)
+	c := mustDial(t, "tcp", addr)
+
+	expectReply(t, c, "OK", "SET", "a", "1", "PX", "50")
+	expectReply(t, c, "OK", "SET", "b", "1", "EX", "60")
+	expectReply(t, c, int64(1), "EXISTS", "a")
+
+	time.Sleep(100 * time.Millisecond)
+
+	expectReply(t, c, int64(0), "EXISTS", "a")
+	expectReply(t, c, []byte("1"), "GET", "b")
+}
+
+func TestUnixSocket(t *testing.T) {
+	_, addr, path := startServer(t, 10)
+
+	tcp := mustDial(t, "tcp", addr)
+	unix := mustDial(t, "unix", path)
+
+	expectReply(t, tcp, "OK", "SET", "shared", "yes")
+	expectReply(t, unix, []byte("yes"), "GET", "shared")
+}
+
+func TestInfo(t *testing.T) {
+	_, addr, _ := startServer(t, 2)
+	c := mustDial(t, "tcp", addr)
+
+	for _, k := range []string{"a", "b", "c"} {
+		expectReply(t, c, "OK", "SET", k, k)
+	}
+
+	expectReply(t, c, []byte("c"), "GET", "c")
+	expectReply(t, c, nil, "GET", "a")
+
+	reply, err := c.do("INFO")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := reply.([]byte)
+
+	for _, line := range []string{"keyspace_hits:1", "keyspace_misses:1", "hit_ratio:0.5000", "evicted_keys:1", "keys:2", "capacity:2"} {
+		if !bytes.Contains(body, []byte(line+"\r\n")) {
+			t.Errorf("expected INFO to contain %q, got %q", line, body)
+		}
+	}
+}
+
+func TestInlineAndPipeline(t *testing.T) {
+	_, addr, _ := startServer(t, 10)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("SET a 1\r\nGET a\r\n*1\r\n$4\r\nPING\r\nQUIT\r\n"))
+
+	r := bufio.NewReader(conn)
+
+	var replies []any
+
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			break
+		}
+
+		replies = append(replies, reply)
+	}
+
+	expected := []any{"OK", []byte("1"), "PONG", "OK"}
+	if !reflect.DeepEqual(replies, expected) {
+		t.Errorf("expected %#v, got %#v", expected, replies)
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	_, addr, _ := startServer(t, 10)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("*1\r\n:1\r\n"))
+
+	reply, _ := readReply(bufio.NewReader(conn))
+	if reply != respError("ERR Protocol error") {
+		t.Errorf("expected protocol error, got %#v", reply)
+	}
+}
+
+func TestCli(t *testing.T) {
+	_, addr, path := startServer(t, 10)
+
+	var stdout, stderr bytes.Buffer
+
+	if code := runClient([]string{"-addr", addr, "SET", "a", "hello"}, &stdout, &stderr); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	runClient([]string{"-unix", path, "GET", "a"}, &stdout, &stderr)
+	runClient([]string{"-unix", path, "GET", "missing"}, &stdout, &stderr)
+	runClient([]string{"-unix", path, "EXISTS", "a"}, &stdout, &stderr)
+
+	if code := runClient([]string{"-addr", addr, "NOPE"}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 for an error reply, got %d", code)
+	}
+
+	expected := strings.Join([]string{"OK", `"hello"`, "(nil)", "(integer) 1", "(error) ERR unknown command 'NOPE'", ""}, "\n")
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+}
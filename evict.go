@@ -0,0 +1,101 @@
+package sieve
+
+import "time"
+
+// EvictReason tells OnEvict why a key left the sieve.
+type EvictReason int
+
+const (
+	// Evicted means the hand removed the key to make room for a new one.
+	Evicted EvictReason = iota
+	// Expired means the key outlived its TTL, or the generation of its namespace.
+	Expired
+	// Deleted means the key was removed by Delete, InvalidateTag or DeletePrefix.
+	Deleted
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case Evicted:
+		return "evicted"
+	case Expired:
+		return "expired"
+	case Deleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// OnEvict is a builder function used to be told about every key that leaves the sieve, except on Flush.
+// The callback runs under the lock of the sieve, so it must be quick and it must not call the sieve.
+func (s *Cache[K, V]) OnEvict(callback func(key K, value V, reason EvictReason)) *Cache[K, V] {
+	s.onEvict = callback
+
+	return s
+}
+
+// WithClock is a builder function used to replace time.Now as the time source of the sieve,
+// so that tests can move the time forward instead of sleeping.
+func (s *Cache[K, V]) WithClock(clock func() time.Time) *Cache[K, V] {
+	s.clock = clock
+
+	return s
+}
+
+// now returns the time of the clock set with WithClock, or the real time.
+func (s *Cache[K, V]) now() time.Time {
+	if s.clock != nil {
+		return s.clock()
+	}
+
+	return now()
+}
+
+// Entry is a key of the sieve with its SIEVE state, as returned by Entries.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+	// Visited is the visited bit, set by a Get since the hand last moved over the key.
+	Visited bool
+	// Hand is true for the key the hand points to, the next one it looks at.
+	Hand bool
+	// Pinned is true for a key pinned with Pin or SetPinned.
+	Pinned bool
+	// ExpiresAt is the deadline set by SetWithTTL, zero when the key has none.
+	ExpiresAt time.Time
+	// Namespace is the name of the namespace holding the key, empty for the keys of the sieve itself.
+	Namespace string
+}
+
+// Entries returns a snapshot of the sieve, from the head, the newest key, to the tail.
+// The expired keys that were not removed yet are included, the negative entries are not.
+func (s *Cache[K, V]) Entries() []Entry[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry[K, V], 0, s.Len())
+
+	for n := s.head; n != nil; n = n.next {
+		if n.absent {
+			continue
+		}
+
+		var namespace string
+		if ns := n.ns(); ns != nil {
+			namespace = ns.name
+		}
+
+		entries = append(entries, Entry[K, V]{
+			Key:       n.key,
+			Value:     n.value,
+			Visited:   n.visited,
+			Hand:      n == s.hand,
+			Pinned:    n.pinned,
+			ExpiresAt: n.expiresAt(),
+			Namespace: namespace,
+		})
+	}
+
+	return entries
+}
@@ -0,0 +1,94 @@
+package sieve_test
+
+import (
+	"testing"
+
+	"github.com/guerinoni/sieve"
+)
+
+func TestOnEvictReasons(t *testing.T) {
+	type event struct {
+		key    string
+		reason sieve.EvictReason
+	}
+
+	var events []event
+
+	s := sieve.WithPrefixIndex(sieve.New[string, int](2)).OnEvict(func(key string, _ int, reason sieve.EvictReason) {
+		events = append(events, event{key, reason})
+	})
+
+	s.Set("a", 1)
+	s.Set("b", 2)
+	s.Set("c", 3)
+	s.Delete("b")
+	s.SetWithTags("d", 4, "t")
+	s.InvalidateTag("t")
+	sieve.DeletePrefix(s, "c")
+
+	ns := s.Namespace("ns")
+	ns.Set("e", 5)
+	ns.Flush()
+	ns.Get("e")
+
+	expected := []event{
+		{"a", sieve.Evicted},
+		{"b", sieve.Deleted},
+		{"d", sieve.Deleted},
+		{"c", sieve.Deleted},
+		{"e", sieve.Expired},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, events)
+	}
+
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected %v at %d, got %v", expected[i], i, events[i])
+		}
+	}
+
+	for reason, name := range map[sieve.EvictReason]string{
+		sieve.Evicted:        "evicted",
+		sieve.Expired:        "expired",
+		sieve.Deleted:        "deleted",
+		sieve.EvictReason(9): "unknown",
+	} {
+		if reason.String() != name {
+			t.Errorf("expected %s, got %s", name, reason)
+		}
+	}
+}
+
+func TestEntries(t *testing.T) {
+	s := sieve.New[int, string](3)
+
+	s.Set(1, one)
+	s.Set(2, "two")
+	s.SetNotFound(3)
+	s.Get(1)
+	s.Pin(2)
+
+	entries := s.Entries()
+
+	// from the head, the negative entry is left out
+	if len(entries) != 2 || entries[0].Key != 2 || entries[1].Key != 1 {
+		t.Fatalf("expected [2 1], got %+v", entries)
+	}
+
+	if !entries[1].Visited || !entries[1].Hand || entries[1].Value != one {
+		t.Errorf("expected 1 visited under the hand, got %+v", entries[1])
+	}
+
+	if entries[0].Visited || entries[0].Hand || !entries[0].Pinned {
+		t.Errorf("expected 2 pinned, got %+v", entries[0])
+	}
+
+	s.Delete(1)
+	s.Namespace("ns").Set(1, "uno")
+
+	if entries := s.Entries(); len(entries) != 2 || entries[0].Namespace != "ns" || entries[1].Namespace != "" {
+		t.Errorf("expected 1 in ns then 2, got %+v", entries)
+	}
+}
@@ -0,0 +1,76 @@
+package sieve
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Histogram is a snapshot of a distribution, of durations or of plain numbers.
+type Histogram[T ~int64] struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing order.
+	Bounds []T
+	// Counts holds the number of observations of each bucket, not cumulative.
+	// It has one more element than Bounds, for the observations above the last bound.
+	Counts []uint64
+	// Count is the total number of observations.
+	Count uint64
+	// Sum is the sum of all the observations.
+	Sum T
+}
+
+// loadBounds are the buckets of the load latencies, from a local cache to a slow backend.
+var loadBounds = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// sweepBounds are the buckets of the sweep lengths, from a victim right under the hand to a full lap of a large sieve.
+var sweepBounds = []int64{0, 1, 4, 16, 64, 256, 1024, 4096, 16384, 65536}
+
+type histogram[T ~int64] struct {
+	bounds []T
+	counts []atomic.Uint64
+	sum    atomic.Int64
+}
+
+func newHistogram[T ~int64](bounds []T) *histogram[T] {
+	return &histogram[T]{
+		bounds: bounds,
+		counts: make([]atomic.Uint64, len(bounds)+1),
+		sum:    atomic.Int64{},
+	}
+}
+
+func (h *histogram[T]) observe(v T) {
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+
+	h.counts[i].Add(1)
+	h.sum.Add(int64(v))
+}
+
+func (h *histogram[T]) snapshot() Histogram[T] {
+	hs := Histogram[T]{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.counts)),
+		Count:  0,
+		Sum:    T(h.sum.Load()),
+	}
+
+	for i := range h.counts {
+		hs.Counts[i] = h.counts[i].Load()
+		hs.Count += hs.Counts[i]
+	}
+
+	return hs
+}
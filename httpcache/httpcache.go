@@ -0,0 +1,350 @@
+// Package httpcache provides a net/http middleware that serves responses from a sieve cache.
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// Response is a response stored in the cache.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// Vary holds the canonical request header names listed in the Vary response header.
+	Vary []string
+
+	// Stored is the time the response was produced by the wrapped handler.
+	Stored time.Time
+}
+
+// Middleware caches the responses of a handler.
+// Responses are keyed by method, host, request URI and the request headers named by Vary.
+type Middleware struct {
+	cache      *sieve.Cache[string, *Response]
+	defaultTTL time.Duration
+}
+
+// New returns a middleware backed by cache.
+func New(cache *sieve.Cache[string, *Response]) *Middleware {
+	return &Middleware{
+		cache:      cache,
+		defaultTTL: 0,
+	}
+}
+
+// WithDefaultTTL is a builder function used to cache responses without max-age for ttl.
+// By default these responses are not cached.
+func (m *Middleware) WithDefaultTTL(ttl time.Duration) *Middleware {
+	m.defaultTTL = ttl
+
+	return m
+}
+
+// Handler wraps next so that cacheable responses are served from the cache.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.serve(next, w, r)
+	})
+}
+
+func (m *Middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		next.ServeHTTP(w, r)
+
+		return
+	}
+
+	reqDirectives := parseCacheControl(r.Header)
+	if _, ok := reqDirectives["no-store"]; ok {
+		next.ServeHTTP(w, r)
+
+		return
+	}
+
+	key := baseKey(r)
+
+	// a shared cache must not give the response to a request with credentials to another user,
+	// unless the origin marked it as shareable (RFC 9111, section 3.5)
+	authorized := r.Header.Get("Authorization") != ""
+
+	// no-cache asks for a fresh response, but the fresh response can still be stored
+	if _, ok := reqDirectives["no-cache"]; !ok {
+		if res, ok := m.lookup(key, r); ok && (!authorized || shareable(res)) {
+			write(w, r, res, "HIT")
+
+			return
+		}
+	}
+
+	// the origin always renders the full response,
+	// conditional headers are answered from what we store
+	origin := r.Clone(r.Context())
+	origin.Header.Del("If-None-Match")
+	origin.Header.Del("If-Modified-Since")
+
+	rec := newRecorder()
+	next.ServeHTTP(rec, origin)
+
+	res := rec.response(now())
+
+	if ttl, ok := m.ttl(res); ok && (!authorized || shareable(res)) {
+		m.store(key, r, res, ttl)
+	}
+
+	write(w, r, res, "MISS")
+}
+
+func (m *Middleware) lookup(key string, r *http.Request) (*Response, bool) {
+	res, ok := m.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	if len(res.Vary) == 0 {
+		return res, true
+	}
+
+	// the entry under the base key only records which headers select the variant
+	return m.cache.Get(variantKey(key, res.Vary, r))
+}
+
+func (m *Middleware) store(key string, r *http.Request, res *Response, ttl time.Duration) {
+	if len(res.Vary) == 0 {
+		m.cache.SetWithTTL(key, res, ttl)
+
+		return
+	}
+
+	m.cache.SetWithTTL(key, &Response{StatusCode: 0, Header: nil, Body: nil, Vary: res.Vary, Stored: res.Stored}, ttl)
+	m.cache.SetWithTTL(variantKey(key, res.Vary, r), res, ttl)
+}
+
+// ttl returns how long the response can be cached, false if it must not be stored.
+func (m *Middleware) ttl(res *Response) (time.Duration, bool) {
+	if !cacheableStatus[res.StatusCode] {
+		return 0, false
+	}
+
+	for _, name := range res.Vary {
+		if name == "*" {
+			return 0, false
+		}
+	}
+
+	// the cookies of one user must not be set for the others
+	if len(res.Header.Values("Set-Cookie")) > 0 {
+		return 0, false
+	}
+
+	directives := parseCacheControl(res.Header)
+
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0, false
+		}
+	}
+
+	// s-maxage is meant for shared caches like this one, so it wins over max-age
+	for _, d := range []string{"s-maxage", "max-age"} {
+		v, ok := directives[d]
+		if !ok {
+			continue
+		}
+
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec <= 0 {
+			return 0, false
+		}
+
+		return time.Duration(sec) * time.Second, true
+	}
+
+	return m.defaultTTL, m.defaultTTL > 0
+}
+
+// shareable reports whether res can be stored for, and served to, requests with an Authorization header.
+func shareable(res *Response) bool {
+	directives := parseCacheControl(res.Header)
+
+	for _, d := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := directives[d]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cacheableStatus holds the status codes that are heuristically cacheable (RFC 9110, section 15.1).
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+func write(w http.ResponseWriter, r *http.Request, res *Response, status string) {
+	h := w.Header()
+
+	for k, v := range res.Header {
+		h[k] = v
+	}
+
+	h.Set("X-Cache", status)
+
+	if status == "HIT" {
+		h.Set("Age", strconv.Itoa(int(now().Sub(res.Stored)/time.Second)))
+	}
+
+	if res.StatusCode == http.StatusOK && notModified(r, res) {
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.WriteHeader(res.StatusCode)
+
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(res.Body)
+	}
+}
+
+// notModified reports whether the If-None-Match header of r matches the ETag of res.
+func notModified(r *http.Request, res *Response) bool {
+	inm := r.Header.Get("If-None-Match")
+	etag := res.Header.Get("ETag")
+
+	if inm == "" || etag == "" {
+		return false
+	}
+
+	for candidate := range strings.SplitSeq(inm, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		// If-None-Match uses the weak comparison
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func baseKey(r *http.Request) string {
+	return r.Method + " " + r.Host + r.URL.RequestURI()
+}
+
+func variantKey(key string, vary []string, r *http.Request) string {
+	var b strings.Builder
+
+	b.WriteString(key)
+
+	for _, name := range vary {
+		b.WriteByte(0)
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	return b.String()
+}
+
+// parseCacheControl returns the Cache-Control directives with their optional value.
+func parseCacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+
+	for _, line := range h.Values("Cache-Control") {
+		for part := range strings.SplitSeq(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return directives
+}
+
+func parseVary(h http.Header) []string {
+	var vary []string
+
+	for _, line := range h.Values("Vary") {
+		for name := range strings.SplitSeq(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			vary = append(vary, http.CanonicalHeaderKey(name))
+		}
+	}
+
+	return vary
+}
+
+// recorder buffers the response of the wrapped handler.
+type recorder struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		header: make(http.Header),
+		body:   bytes.Buffer{},
+		status: 0,
+	}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+
+	return r.body.Write(p)
+}
+
+func (r *recorder) response(at time.Time) *Response {
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &Response{
+		StatusCode: status,
+		Header:     r.header,
+		Body:       r.body.Bytes(),
+		Vary:       parseVary(r.header),
+		Stored:     at,
+	}
+}
+
+// now is real `time.Now` function.
+// It is a variable to make it easier to mock in tests.
+var now = time.Now
@@ -0,0 +1,337 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// countingHandler answers with the number of times it was called.
+func countingHandler(cacheControl string, calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, "call %d", *calls)
+	})
+}
+
+func do(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+
+	for k, v := range header {
+		r.Header[k] = v
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestHit(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(countingHandler("max-age=60", &calls))
+
+	first := do(h, http.MethodGet, "/a", nil)
+	second := do(h, http.MethodGet, "/a", nil)
+
+	if calls != 1 {
+		t.Errorf("expected 1 call to the handler, got %d", calls)
+	}
+
+	if got := first.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("expected first response to be a MISS, got %q", got)
+	}
+
+	if got := second.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("expected second response to be a HIT, got %q", got)
+	}
+
+	if second.Body.String() != "call 1" {
+		t.Errorf("expected cached body 'call 1', got %q", second.Body.String())
+	}
+
+	if second.Header().Get("ETag") != `"v1"` {
+		t.Errorf("expected cached ETag, got %q", second.Header().Get("ETag"))
+	}
+
+	do(h, http.MethodGet, "/b", nil)
+
+	if calls != 2 {
+		t.Errorf("expected a different URL to reach the handler, got %d calls", calls)
+	}
+}
+
+func TestNotCached(t *testing.T) {
+	for _, cc := range []string{"", "no-store", "private, max-age=60", "no-cache", "max-age=0", "max-age=abc"} {
+		t.Run(cc, func(t *testing.T) {
+			calls := 0
+			h := New(sieve.New[string, *Response](10)).Handler(countingHandler(cc, &calls))
+
+			do(h, http.MethodGet, "/a", nil)
+			do(h, http.MethodGet, "/a", nil)
+
+			if calls != 2 {
+				t.Errorf("expected 2 calls to the handler, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestMethodsAndStatus(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		w.Header().Set("Cache-Control", "max-age=60")
+
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	do(h, http.MethodPost, "/a", nil)
+	do(h, http.MethodPost, "/a", nil)
+	do(h, http.MethodGet, "/error", nil)
+	do(h, http.MethodGet, "/error", nil)
+
+	if calls != 4 {
+		t.Errorf("expected 4 calls to the handler, got %d", calls)
+	}
+
+	do(h, http.MethodHead, "/a", nil)
+	do(h, http.MethodHead, "/a", nil)
+
+	if calls != 5 {
+		t.Errorf("expected HEAD to be cached, got %d calls", calls)
+	}
+}
+
+func TestRequestCacheControl(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(countingHandler("max-age=60", &calls))
+
+	do(h, http.MethodGet, "/a", nil)
+
+	w := do(h, http.MethodGet, "/a", http.Header{"Cache-Control": {"no-cache"}})
+	if w.Header().Get("X-Cache") != "MISS" || calls != 2 {
+		t.Errorf("expected no-cache to reach the handler")
+	}
+
+	// the response to no-cache replaced the stored one
+	w = do(h, http.MethodGet, "/a", nil)
+	if w.Body.String() != "call 2" {
+		t.Errorf("expected body 'call 2', got %q", w.Body.String())
+	}
+
+	do(h, http.MethodGet, "/a", http.Header{"Cache-Control": {"no-store"}})
+
+	if calls != 3 {
+		t.Errorf("expected no-store to reach the handler, got %d calls", calls)
+	}
+}
+
+func TestVary(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "accept-language")
+		fmt.Fprint(w, r.Header.Get("Accept-Language"))
+	}))
+
+	en := http.Header{"Accept-Language": {"en"}}
+	it := http.Header{"Accept-Language": {"it"}}
+
+	do(h, http.MethodGet, "/a", en)
+	do(h, http.MethodGet, "/a", it)
+
+	if w := do(h, http.MethodGet, "/a", en); w.Body.String() != "en" || w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected cached 'en' variant, got %q", w.Body.String())
+	}
+
+	if w := do(h, http.MethodGet, "/a", it); w.Body.String() != "it" || w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected cached 'it' variant, got %q", w.Body.String())
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls to the handler, got %d", calls)
+	}
+}
+
+func TestVaryStar(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "*")
+	}))
+
+	do(h, http.MethodGet, "/a", nil)
+	do(h, http.MethodGet, "/a", nil)
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls to the handler, got %d", calls)
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(countingHandler("max-age=60", &calls))
+
+	// the miss is answered from the stored response too
+	w := do(h, http.MethodGet, "/a", http.Header{"If-None-Match": {`"v1"`}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 on miss, got %d", w.Code)
+	}
+
+	w = do(h, http.MethodGet, "/a", http.Header{"If-None-Match": {`"v0", W/"v1"`}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected empty 304 on hit, got %d with %q", w.Code, w.Body.String())
+	}
+
+	w = do(h, http.MethodGet, "/a", http.Header{"If-None-Match": {`"v2"`}})
+	if w.Code != http.StatusOK || w.Body.String() != "call 1" {
+		t.Errorf("expected full 200 for a stale ETag, got %d with %q", w.Code, w.Body.String())
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call to the handler, got %d", calls)
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).WithDefaultTTL(time.Minute).Handler(countingHandler("", &calls))
+
+	do(h, http.MethodGet, "/a", nil)
+	do(h, http.MethodGet, "/a", nil)
+
+	if calls != 1 {
+		t.Errorf("expected 1 call to the handler, got %d", calls)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	m := New(sieve.New[string, *Response](10))
+
+	tests := []struct {
+		cacheControl string
+		expected     time.Duration
+		ok           bool
+	}{
+		{"max-age=60", time.Minute, true},
+		{"public, max-age=10", 10 * time.Second, true},
+		{"max-age=60, s-maxage=5", 5 * time.Second, true},
+		{`max-age="30"`, 30 * time.Second, true},
+		{"MAX-AGE=1", time.Second, true},
+		{"max-age=-1", 0, false},
+		{"no-store, max-age=60", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		res := &Response{StatusCode: http.StatusOK, Header: http.Header{"Cache-Control": {tt.cacheControl}}}
+
+		ttl, ok := m.ttl(res)
+		if ttl != tt.expected || ok != tt.ok {
+			t.Errorf("%q: expected (%v, %v), got (%v, %v)", tt.cacheControl, tt.expected, tt.ok, ttl, ok)
+		}
+	}
+}
+
+func TestAge(t *testing.T) {
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	defer func() { now = time.Now }()
+
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(countingHandler("max-age=60", &calls))
+
+	do(h, http.MethodGet, "/a", nil)
+
+	sec = 11
+
+	if w := do(h, http.MethodGet, "/a", nil); w.Header().Get("Age") != "10" {
+		t.Errorf("expected Age 10, got %q", w.Header().Get("Age"))
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+	bob := http.Header{"Authorization": {"Bearer bob"}}
+
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(countingHandler("max-age=60", &calls))
+
+	do(h, http.MethodGet, "/a", alice)
+
+	w := do(h, http.MethodGet, "/a", bob)
+	if got := w.Header().Get("X-Cache"); got != "MISS" || w.Body.String() != "call 2" {
+		t.Errorf("expected the response to alice to not be served to bob, got %s %q", got, w.Body.String())
+	}
+
+	// nor is it served to a request with credentials from what an anonymous request stored
+	do(h, http.MethodGet, "/b", nil)
+
+	if w := do(h, http.MethodGet, "/b", bob); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected a request with credentials to bypass the cache")
+	}
+
+	if calls != 4 {
+		t.Errorf("expected 4 calls to the handler, got %d", calls)
+	}
+}
+
+func TestAuthorizationShareable(t *testing.T) {
+	for _, cc := range []string{"public, max-age=60", "s-maxage=60", "max-age=60, must-revalidate"} {
+		t.Run(cc, func(t *testing.T) {
+			calls := 0
+			h := New(sieve.New[string, *Response](10)).Handler(countingHandler(cc, &calls))
+
+			do(h, http.MethodGet, "/a", http.Header{"Authorization": {"Bearer alice"}})
+
+			w := do(h, http.MethodGet, "/a", http.Header{"Authorization": {"Bearer bob"}})
+			if got := w.Header().Get("X-Cache"); got != "HIT" {
+				t.Errorf("expected a shareable response to be a HIT, got %q", got)
+			}
+
+			if calls != 1 {
+				t.Errorf("expected 1 call to the handler, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	calls := 0
+	h := New(sieve.New[string, *Response](10)).Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Add("Set-Cookie", fmt.Sprintf("session=%d", calls))
+	}))
+
+	do(h, http.MethodGet, "/a", nil)
+
+	w := do(h, http.MethodGet, "/a", nil)
+	if got := w.Header().Get("Set-Cookie"); got != "session=2" {
+		t.Errorf("expected a response with Set-Cookie to not be stored, got cookie %q", got)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls to the handler, got %d", calls)
+	}
+}
@@ -0,0 +1,317 @@
+package sieve
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync"
+)
+
+// IndexedCache is a SIEVE cache specialized for uint64 and string keys.
+// The nodes live in one slice and link to each other by index, and an open-addressing table
+// with linear probing replaces the map, so a Set allocates nothing once the cache is full.
+// It implements the core operations of Cache only.
+type IndexedCache[K comparable, V any] struct {
+	hash func(K) uint64
+
+	nodes []inode[K, V]
+	// free holds the indexes of the nodes freed by Delete, to reuse before growing nodes.
+	free []int32
+
+	// table maps the slots to the node indexes plus one, zero is an empty slot.
+	table []int32
+	mask  uint64
+
+	head, tail, hand int32
+
+	capacity int32
+	len      int32
+
+	mu sync.Locker
+}
+
+// none is the index of a missing node.
+const none = -1
+
+type inode[K comparable, V any] struct {
+	key   K
+	hash  uint64
+	value V
+
+	prev, next int32
+
+	visited bool
+}
+
+// emptyInode returns an unused node, linked to nothing.
+func emptyInode[K comparable, V any]() inode[K, V] {
+	var (
+		key   K
+		value V
+	)
+
+	return inode[K, V]{key: key, hash: 0, value: value, prev: none, next: none, visited: false}
+}
+
+// NewUint64 returns a new IndexedCache keyed by uint64.
+// If the size is less than or equal to zero, it panics.
+func NewUint64[V any](size int32) *IndexedCache[uint64, V] {
+	return newIndexed[uint64, V](size, mix)
+}
+
+// NewString returns a new IndexedCache keyed by string, the keys are hashed once per operation with hash/maphash.
+// If the size is less than or equal to zero, it panics.
+func NewString[V any](size int32) *IndexedCache[string, V] {
+	seed := maphash.MakeSeed()
+
+	return newIndexed[string, V](size, func(k string) uint64 {
+		return maphash.String(seed, k)
+	})
+}
+
+func newIndexed[K comparable, V any](size int32, hash func(K) uint64) *IndexedCache[K, V] {
+	if size <= 0 {
+		panic("sieve: size must be greater than zero")
+	}
+
+	// at most half full, so the probes stay short
+	slots := uint64(1) << bits.Len64(uint64(size)*2-1)
+
+	return &IndexedCache[K, V]{
+		hash:     hash,
+		nodes:    make([]inode[K, V], 0, size),
+		free:     nil,
+		table:    make([]int32, slots),
+		mask:     slots - 1,
+		head:     none,
+		tail:     none,
+		hand:     none,
+		capacity: size,
+		len:      0,
+		mu:       &sync.Mutex{},
+	}
+}
+
+// mix is the finalizer of splitmix64, it spreads the bits of integer keys that are often sequential.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+// WithoutLock is a builder function used to drop the lock, like NewSingleThread does for Cache.
+func (s *IndexedCache[K, V]) WithoutLock() *IndexedCache[K, V] {
+	s.mu = noopMutex{}
+
+	return s
+}
+
+// Len returns the number of elements in the cache.
+func (s *IndexedCache[K, V]) Len() int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.len
+}
+
+// find returns the slot of the key, or the empty slot where it would go, and the node index, or none.
+func (s *IndexedCache[K, V]) find(key K, h uint64) (uint64, int32) {
+	for slot := h & s.mask; ; slot = (slot + 1) & s.mask {
+		idx := s.table[slot] - 1
+		if idx == none {
+			return slot, none
+		}
+
+		if n := &s.nodes[idx]; n.hash == h && n.key == key {
+			return slot, idx
+		}
+	}
+}
+
+// Get returns the value associated with the key, like Cache.Get.
+func (s *IndexedCache[K, V]) Get(key K) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, idx := s.find(key, s.hash(key))
+	if idx == none {
+		var zeroValue V
+
+		return zeroValue, false
+	}
+
+	n := &s.nodes[idx]
+	n.visited = true
+
+	return n.value, true
+}
+
+// Contains reports whether the key is in the cache, without marking it as visited.
+func (s *IndexedCache[K, V]) Contains(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, idx := s.find(key, s.hash(key))
+
+	return idx != none
+}
+
+// Set inserts a key-value pair, like Cache.Set.
+func (s *IndexedCache[K, V]) Set(key K, value V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.hash(key)
+
+	if _, idx := s.find(key, h); idx != none {
+		n := &s.nodes[idx]
+		n.visited = true
+		n.value = value
+
+		return
+	}
+
+	if s.len == s.capacity {
+		s.evict()
+	}
+
+	idx := s.alloc()
+	s.nodes[idx] = inode[K, V]{key: key, hash: h, value: value, prev: none, next: s.head, visited: false}
+
+	// the eviction may have moved the keys around, look for the empty slot again
+	slot, _ := s.find(key, h)
+	s.table[slot] = idx + 1
+
+	if s.head != none {
+		s.nodes[s.head].prev = idx
+	}
+
+	s.head = idx
+
+	if s.tail == none {
+		s.tail = idx
+		s.hand = idx
+	}
+
+	s.len++
+}
+
+// Delete removes the key, like Cache.Delete.
+func (s *IndexedCache[K, V]) Delete(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot, idx := s.find(key, s.hash(key))
+	if idx == none {
+		return false
+	}
+
+	s.remove(slot, idx)
+
+	return true
+}
+
+// Flush removes all elements from the cache.
+func (s *IndexedCache[K, V]) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.table)
+
+	s.nodes = s.nodes[:0]
+	s.free = nil
+	s.head, s.tail, s.hand = none, none, none
+	s.len = 0
+}
+
+// alloc returns the index of an unused node.
+func (s *IndexedCache[K, V]) alloc() int32 {
+	if n := len(s.free); n > 0 {
+		idx := s.free[n-1]
+		s.free = s.free[:n-1]
+
+		return idx
+	}
+
+	s.nodes = append(s.nodes, emptyInode[K, V]())
+
+	return int32(len(s.nodes) - 1)
+}
+
+// evict removes the victim of the hand, like Cache.evictNode without the expiration.
+func (s *IndexedCache[K, V]) evict() {
+	h := s.hand
+
+	for s.nodes[h].visited {
+		s.nodes[h].visited = false
+
+		h = s.nodes[h].prev
+		if h == none {
+			h = s.tail
+		}
+	}
+
+	// the hand restarts from the node after the victim
+	s.hand = h
+
+	n := &s.nodes[h]
+	slot, _ := s.find(n.key, n.hash)
+
+	s.remove(slot, h)
+}
+
+// remove unlinks the node at idx, stored at slot, and frees both.
+func (s *IndexedCache[K, V]) remove(slot uint64, idx int32) {
+	n := &s.nodes[idx]
+
+	// the hand keeps moving towards the head
+	if s.hand == idx {
+		s.hand = n.prev
+	}
+
+	if n.prev != none {
+		s.nodes[n.prev].next = n.next
+	} else {
+		s.head = n.next
+	}
+
+	if n.next != none {
+		s.nodes[n.next].prev = n.prev
+	} else {
+		s.tail = n.prev
+	}
+
+	if s.hand == none {
+		s.hand = s.tail
+	}
+
+	s.deleteSlot(slot)
+
+	// drop the references held by the key and the value
+	*n = emptyInode[K, V]()
+
+	s.free = append(s.free, idx)
+	s.len--
+}
+
+// deleteSlot empties slot, shifting back the keys that probed past it, so that no tombstone is needed.
+func (s *IndexedCache[K, V]) deleteSlot(slot uint64) {
+	for next := (slot + 1) & s.mask; ; next = (next + 1) & s.mask {
+		idx := s.table[next] - 1
+		if idx == none {
+			break
+		}
+
+		// the key at next can move to slot only if its home is not between slot and next
+		home := s.nodes[idx].hash & s.mask
+		if (next-home)&s.mask >= (next-slot)&s.mask {
+			s.table[slot] = s.table[next]
+			slot = next
+		}
+	}
+
+	s.table[slot] = 0
+}
@@ -0,0 +1,179 @@
+package sieve_test
+
+import (
+	"bufio"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/guerinoni/sieve"
+)
+
+func TestIndexedPanicWithSizeZero(t *testing.T) {
+	defer func() {
+		if r := recover(); r != panicError {
+			t.Errorf("expected panic %q, got %v", panicError, r)
+		}
+	}()
+
+	sieve.NewUint64[int](0)
+}
+
+func TestIndexedSameAsCache(t *testing.T) {
+	for round := range 20 {
+		rnd := rand.New(rand.NewPCG(uint64(round), 0))
+
+		s := sieve.New[uint64, int](8)
+		u := sieve.NewUint64[int](8)
+		str := sieve.NewString[int](8).WithoutLock()
+
+		for step := range 2000 {
+			key := rnd.Uint64N(24)
+			skey := strconv.FormatUint(key, 10)
+
+			switch rnd.IntN(4) {
+			case 0, 1:
+				s.Set(key, step)
+				u.Set(key, step)
+				str.Set(skey, step)
+			case 2:
+				v, ok := s.Get(key)
+
+				if uv, uok := u.Get(key); uv != v || uok != ok {
+					t.Fatalf("round %d step %d: expected Get(%d) to be %d, %v, got %d, %v", round, step, key, v, ok, uv, uok)
+				}
+
+				if sv, sok := str.Get(skey); sv != v || sok != ok {
+					t.Fatalf("round %d step %d: expected Get(%q) to be %d, %v, got %d, %v", round, step, skey, v, ok, sv, sok)
+				}
+			case 3:
+				ok := s.Delete(key)
+
+				if u.Delete(key) != ok || str.Delete(skey) != ok {
+					t.Fatalf("round %d step %d: expected Delete(%d) to be %v", round, step, key, ok)
+				}
+			}
+
+			if u.Len() != s.Len() || str.Len() != s.Len() {
+				t.Fatalf("round %d step %d: expected len %d, got %d and %d", round, step, s.Len(), u.Len(), str.Len())
+			}
+
+			for k := range uint64(24) {
+				ok := s.Contains(k)
+
+				if u.Contains(k) != ok || str.Contains(strconv.FormatUint(k, 10)) != ok {
+					t.Fatalf("round %d step %d: expected Contains(%d) to be %v", round, step, k, ok)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexedFlush(t *testing.T) {
+	s := sieve.NewString[int](4)
+
+	for i := range 10 {
+		s.Set(strconv.Itoa(i), i)
+	}
+
+	s.Flush()
+
+	if s.Len() != 0 {
+		t.Errorf("expected an empty sieve, got len %d", s.Len())
+	}
+
+	if s.Contains("9") {
+		t.Errorf("expected 9 to be flushed")
+	}
+
+	s.Set("a", 1)
+
+	if v, ok := s.Get("a"); !ok || v != 1 {
+		t.Errorf("expected a to be 1 after the flush, got %d, %v", v, ok)
+	}
+}
+
+// inputLines returns the lines of the test input, to replay them b.N times.
+func inputLines(b *testing.B) []string {
+	b.Helper()
+
+	f, err := os.Open(testInputFile)
+	if err != nil {
+		b.Fatalf("could not open file %s: %v", testInputFile, err)
+	}
+	defer f.Close()
+
+	var lines []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines
+}
+
+// replay runs the access pattern of BenchmarkBigInput, one line per iteration.
+func replay[K comparable](b *testing.B, keys []K, get func(K) bool, set func(K)) {
+	b.Helper()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := range b.N {
+		k := keys[i%len(keys)]
+		if !get(k) {
+			set(k)
+		}
+	}
+}
+
+func BenchmarkBigInputString(b *testing.B) {
+	s := sieve.New[string, string](1000)
+
+	replay(b, inputLines(b),
+		func(k string) bool { _, ok := s.Get(k); return ok },
+		func(k string) { s.Set(k, k) })
+}
+
+func BenchmarkBigInputIndexedString(b *testing.B) {
+	s := sieve.NewString[string](1000)
+
+	replay(b, inputLines(b),
+		func(k string) bool { _, ok := s.Get(k); return ok },
+		func(k string) { s.Set(k, k) })
+}
+
+func uint64Lines(b *testing.B) []uint64 {
+	b.Helper()
+
+	lines := inputLines(b)
+	keys := make([]uint64, len(lines))
+
+	for i, l := range lines {
+		k, err := strconv.ParseUint(l, 10, 64)
+		if err != nil {
+			b.Fatalf("could not parse line %d: %v", i, err)
+		}
+
+		keys[i] = k
+	}
+
+	return keys
+}
+
+func BenchmarkBigInputUint64(b *testing.B) {
+	s := sieve.New[uint64, uint64](1000)
+
+	replay(b, uint64Lines(b),
+		func(k uint64) bool { _, ok := s.Get(k); return ok },
+		func(k uint64) { s.Set(k, k) })
+}
+
+func BenchmarkBigInputIndexedUint64(b *testing.B) {
+	s := sieve.NewUint64[uint64](1000)
+
+	replay(b, uint64Lines(b),
+		func(k uint64) bool { _, ok := s.Get(k); return ok },
+		func(k uint64) { s.Set(k, k) })
+}
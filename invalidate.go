@@ -0,0 +1,232 @@
+package sieve
+
+import (
+	"strings"
+	"time"
+)
+
+// SetWithTags inserts a key-value pair like Set and attaches the tags to it,
+// so that InvalidateTag can later remove every key sharing a tag.
+// Every write replaces the tags of the key, so Set drops them.
+func (s *Cache[K, V]) SetWithTags(key K, value V, tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := s.set(key, value, time.Time{}); n != nil {
+		s.tag(n, tags)
+	}
+}
+
+// InvalidateTag removes all the keys tagged with tag and returns how many they were.
+func (s *Cache[K, V]) InvalidateTag(tag string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes := s.tags[tag]
+	count := len(nodes)
+
+	// removeNode drops each node from nodes too, which is fine while ranging over it
+	for n := range nodes {
+		s.removeNode(n, Deleted)
+	}
+
+	return count
+}
+
+// tag attaches the tags to n, the node must not have any.
+func (s *Cache[K, V]) tag(n *node[K, V], tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	if s.tags == nil {
+		s.tags = make(map[string]map[*node[K, V]]struct{})
+	}
+
+	e := n.ext()
+	e.tags = make([]string, 0, len(tags))
+
+	for _, t := range tags {
+		nodes, ok := s.tags[t]
+		if !ok {
+			nodes = make(map[*node[K, V]]struct{})
+			s.tags[t] = nodes
+		}
+
+		// the same tag twice is attached once
+		if _, ok := nodes[n]; ok {
+			continue
+		}
+
+		nodes[n] = struct{}{}
+		e.tags = append(e.tags, t)
+	}
+}
+
+// untag detaches every tag of n, dropping the tags left without keys.
+func (s *Cache[K, V]) untag(n *node[K, V]) {
+	if n.extra == nil {
+		return
+	}
+
+	for _, t := range n.extra.tags {
+		nodes := s.tags[t]
+
+		delete(nodes, n)
+
+		if len(nodes) == 0 {
+			delete(s.tags, t)
+		}
+	}
+
+	n.extra.tags = nil
+}
+
+// WithPrefixIndex is a builder function used to keep the string keys in a trie,
+// so that DeletePrefix visits only the matching keys instead of all of them.
+// It must be called before the first key is inserted.
+func WithPrefixIndex[V any](c *Cache[string, V]) *Cache[string, V] {
+	c.prefixes = newTrie[string, V]()
+
+	return c
+}
+
+// DeletePrefix removes all the keys starting with prefix and returns how many they were.
+// Without WithPrefixIndex every key of the sieve is checked.
+func DeletePrefix[V any](c *Cache[string, V], prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var nodes []*node[string, V]
+
+	if c.prefixes != nil {
+		nodes = c.prefixes.withPrefix(prefix)
+	} else {
+		for k, n := range c.m {
+			if strings.HasPrefix(k, prefix) {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+
+	for _, n := range nodes {
+		c.removeNode(n, Deleted)
+	}
+
+	return len(nodes)
+}
+
+// trie indexes the nodes of a sieve with string keys by their bytes.
+// K is always string, it is generic only to live in Cache.
+type trie[K comparable, V any] struct {
+	root *trieNode[K, V]
+}
+
+type trieNode[K comparable, V any] struct {
+	children map[byte]*trieNode[K, V]
+	// n is the node whose key ends here, nil if there is none
+	n *node[K, V]
+}
+
+func newTrie[K comparable, V any]() *trie[K, V] {
+	return &trie[K, V]{root: &trieNode[K, V]{children: nil, n: nil}}
+}
+
+func (t *trie[K, V]) insert(n *node[K, V]) {
+	cur := t.root
+
+	for _, b := range []byte(any(n.key).(string)) {
+		next, ok := cur.children[b]
+		if !ok {
+			if cur.children == nil {
+				cur.children = make(map[byte]*trieNode[K, V])
+			}
+
+			next = &trieNode[K, V]{children: nil, n: nil}
+			cur.children[b] = next
+		}
+
+		cur = next
+	}
+
+	cur.n = n
+}
+
+func (t *trie[K, V]) remove(n *node[K, V]) {
+	key := any(n.key).(string)
+
+	// path holds the trie nodes from the root to the key, to prune the empty branches
+	path := make([]*trieNode[K, V], 0, len(key)+1)
+	path = append(path, t.root)
+
+	cur := t.root
+
+	for i := range len(key) {
+		next, ok := cur.children[key[i]]
+		if !ok {
+			return
+		}
+
+		path = append(path, next)
+		cur = next
+	}
+
+	cur.n = nil
+
+	for i := len(path) - 1; i > 0; i-- {
+		if path[i].n != nil || len(path[i].children) > 0 {
+			break
+		}
+
+		delete(path[i-1].children, key[i-1])
+	}
+}
+
+// contains reports whether n is indexed under its key.
+func (t *trie[K, V]) contains(n *node[K, V]) bool {
+	cur := t.root
+
+	for _, b := range []byte(any(n.key).(string)) {
+		next, ok := cur.children[b]
+		if !ok {
+			return false
+		}
+
+		cur = next
+	}
+
+	return cur.n == n
+}
+
+// withPrefix returns the nodes whose key starts with prefix.
+func (t *trie[K, V]) withPrefix(prefix string) []*node[K, V] {
+	cur := t.root
+
+	for i := range len(prefix) {
+		next, ok := cur.children[prefix[i]]
+		if !ok {
+			return nil
+		}
+
+		cur = next
+	}
+
+	var nodes []*node[K, V]
+
+	stack := []*trieNode[K, V]{cur}
+
+	for len(stack) > 0 {
+		tn := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if tn.n != nil {
+			nodes = append(nodes, tn.n)
+		}
+
+		for _, child := range tn.children {
+			stack = append(stack, child)
+		}
+	}
+
+	return nodes
+}
@@ -0,0 +1,174 @@
+package sieve
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	s := New[int, string](10)
+
+	s.SetWithTags(1, "one", "tenant:a", "user")
+	s.SetWithTags(2, "two", "tenant:a")
+	s.SetWithTags(3, "three", "tenant:b", "user")
+	s.Set(4, "four")
+	s.SetWithTags(5, "five")
+
+	if n := s.InvalidateTag("tenant:a"); n != 2 {
+		t.Errorf("expected 2 keys invalidated, got %d", n)
+	}
+
+	if s.Contains(1) || s.Contains(2) || !s.Contains(3) || !s.Contains(4) || !s.Contains(5) {
+		t.Errorf("expected only 3, 4 and 5 to be left, got %s", s)
+	}
+
+	// 1 left the index of its other tag too
+	if n := s.InvalidateTag("user"); n != 1 {
+		t.Errorf("expected 1 key invalidated, got %d", n)
+	}
+
+	if n := s.InvalidateTag("unknown"); n != 0 {
+		t.Errorf("expected nothing invalidated, got %d", n)
+	}
+
+	if len(s.tags) != 0 {
+		t.Errorf("expected the tag index to be empty, got %v", s.tags)
+	}
+}
+
+func TestInvalidateTagAfterWrite(t *testing.T) {
+	s := New[int, string](10)
+
+	s.SetWithTags(1, "one", "a")
+	s.SetWithTags(2, "two", "a")
+
+	// every write replaces the tags
+	s.Set(1, "uno")
+	s.SetWithTags(2, "due", "b")
+
+	if n := s.InvalidateTag("a"); n != 0 {
+		t.Errorf("expected nothing invalidated, got %d", n)
+	}
+
+	if n := s.InvalidateTag("b"); n != 1 || s.Contains(2) {
+		t.Errorf("expected 2 to be invalidated, got %d", n)
+	}
+}
+
+func TestInvalidateTagEviction(t *testing.T) {
+	s := New[int, string](2)
+
+	s.SetWithTags(1, "one", "a")
+	s.SetWithTags(2, "two", "a")
+	s.SetWithTags(3, "three", "a")
+
+	// 1 was evicted and is not in the index anymore
+	if n := s.InvalidateTag("a"); n != 2 || s.Len() != 0 {
+		t.Errorf("expected 2 keys invalidated and an empty sieve, got %d and %d", n, s.Len())
+	}
+
+	// the list was repaired, the sieve works as new
+	s.Set(4, "four")
+	s.Set(5, "five")
+	s.Set(6, "six")
+
+	if s.Len() != 2 || s.head.key != 6 || s.tail.key != 5 {
+		t.Errorf("expected [6 -> 5], got %s", s)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("indexed=%v", indexed), func(t *testing.T) {
+			s := New[string, int](4)
+			if indexed {
+				s = WithPrefixIndex(s)
+			}
+
+			s.Set("tenant:a:1", 1)
+			s.Set("tenant:a:2", 2)
+			s.Set("tenant:ab", 3)
+			s.Set("tenant:b:1", 4)
+
+			if n := DeletePrefix(s, "tenant:a:"); n != 2 {
+				t.Errorf("expected 2 keys deleted, got %d", n)
+			}
+
+			if s.Contains("tenant:a:1") || !s.Contains("tenant:ab") || !s.Contains("tenant:b:1") {
+				t.Errorf("expected only the tenant:a: keys to be deleted, got %s", s)
+			}
+
+			// fill and evict through the hand after the removals
+			s.Set("x", 5)
+			s.Set("y", 6)
+			s.Set("z", 7)
+
+			if s.Len() != 4 {
+				t.Errorf("expected 4 keys, got %d", s.Len())
+			}
+
+			if n := DeletePrefix(s, ""); n != 4 || s.Len() != 0 {
+				t.Errorf("expected every key to be deleted, got %d and %d left", n, s.Len())
+			}
+		})
+	}
+}
+
+func TestPrefixIndexPruned(t *testing.T) {
+	s := WithPrefixIndex(New[string, int](2))
+
+	s.Set("abc", 1)
+	s.Set("abd", 2)
+	s.Set("x", 3) // evicts abc
+
+	if n := DeletePrefix(s, "ab"); n != 1 {
+		t.Errorf("expected 1 key deleted, got %d", n)
+	}
+
+	// only the branch of x is left
+	if len(s.prefixes.root.children) != 1 {
+		t.Errorf("expected the empty branches to be pruned, got %v", s.prefixes.root.children)
+	}
+
+	// a key missing from the index leaves it as it is
+	s.prefixes.remove(newNode("xy", 0))
+
+	if len(s.prefixes.root.children) != 1 || s.prefixes.root.children['x'].n == nil {
+		t.Errorf("expected x to stay in the index")
+	}
+
+	s.Flush()
+
+	if len(s.prefixes.root.children) != 0 {
+		t.Errorf("expected Flush to empty the index")
+	}
+}
+
+func BenchmarkDeletePrefix(b *testing.B) {
+	for _, indexed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("indexed=%v", indexed), func(b *testing.B) {
+			s := New[string, int](100_000)
+			if indexed {
+				s = WithPrefixIndex(s)
+			}
+
+			for i := range 100_000 {
+				s.Set(fmt.Sprintf("tenant:%d:%d", i%1000, i), i)
+			}
+
+			b.ResetTimer()
+
+			for i := range b.N {
+				tenant := fmt.Sprintf("tenant:%d:", i%1000)
+
+				DeletePrefix(s, tenant)
+
+				b.StopTimer()
+				for j := range 100 {
+					s.Set(fmt.Sprintf("%s%d", tenant, j), j)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
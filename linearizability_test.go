@@ -0,0 +1,313 @@
+package sieve
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// opKind is the kind of a recorded operation.
+type opKind int
+
+const (
+	opGet opKind = iota
+	opSet
+	opDelete
+)
+
+// operation is a call recorded in a history, with the logical times of its call and return.
+type operation struct {
+	kind  opKind
+	key   int
+	value int
+
+	// outputs
+	result int
+	ok     bool
+
+	call, ret int64
+}
+
+func (o operation) String() string {
+	switch o.kind {
+	case opGet:
+		return fmt.Sprintf("Get(%d) = %d, %v", o.key, o.result, o.ok)
+	case opSet:
+		return fmt.Sprintf("Set(%d, %d)", o.key, o.value)
+	default:
+		return fmt.Sprintf("Delete(%d) = %v", o.key, o.ok)
+	}
+}
+
+// recorder collects the operations of concurrent clients on a sieve.
+type recorder struct {
+	clock atomic.Int64
+
+	mu  sync.Mutex
+	ops []operation
+}
+
+func (r *recorder) do(s *Cache[int, int], op operation) {
+	op.call = r.clock.Add(1)
+
+	switch op.kind {
+	case opGet:
+		op.result, op.ok = s.Get(op.key)
+	case opSet:
+		s.Set(op.key, op.value)
+	case opDelete:
+		op.ok = s.Delete(op.key)
+	}
+
+	op.ret = r.clock.Add(1)
+
+	r.mu.Lock()
+	r.ops = append(r.ops, op)
+	r.mu.Unlock()
+}
+
+// clone returns a copy of the model that can be changed independently.
+func (m *model) clone() *model {
+	return &model{entries: slices.Clone(m.entries), hand: m.hand, capacity: m.capacity, ttl: m.ttl}
+}
+
+// key identifies the state of the model, to remember the states already explored.
+func (m *model) key() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d|", m.hand)
+
+	for _, e := range m.entries {
+		fmt.Fprintf(&b, "%d:%d:%v,", e.key, e.value, e.visited)
+	}
+
+	return b.String()
+}
+
+// step applies op to the model and reports whether the outputs are the ones the model gives.
+func (m *model) step(op operation) bool {
+	switch op.kind {
+	case opGet:
+		v, ok := m.get(op.key, time.Time{})
+
+		return v == op.result && ok == op.ok
+	case opSet:
+		m.set(op.key, op.value, time.Time{})
+
+		return true
+	default:
+		return m.delete(op.key) == op.ok
+	}
+}
+
+// event is a call or a return of an operation, in a doubly linked list sorted by time.
+type event struct {
+	op     int
+	call   bool
+	match  *event
+	prev   *event
+	next   *event
+	lifted bool
+}
+
+// linearizable reports whether the history can be ordered sequentially, respecting real time,
+// so that every output is the one of the model. It is the search of Wing, Gong and Lowe,
+// with the memoization of the explored states used by Porcupine.
+// On failure it returns the longest prefix it could linearize, to help debugging.
+func linearizable(ops []operation, initial *model) (bool, []operation) {
+	type timed struct {
+		at int64
+		ev *event
+	}
+
+	all := make([]timed, 0, 2*len(ops))
+
+	for i, op := range ops {
+		call := &event{op: i, call: true, match: nil, prev: nil, next: nil, lifted: false}
+		ret := &event{op: i, call: false, match: nil, prev: nil, next: nil, lifted: false}
+		call.match = ret
+
+		all = append(all, timed{at: op.call, ev: call}, timed{at: op.ret, ev: ret})
+	}
+
+	slices.SortFunc(all, func(a, b timed) int { return int(a.at - b.at) })
+
+	// a sentinel head makes lifting the first event like any other
+	head := &event{op: -1, call: false, match: nil, prev: nil, next: nil, lifted: false}
+	last := head
+
+	for _, t := range all {
+		last.next = t.ev
+		t.ev.prev = last
+		last = t.ev
+	}
+
+	lift := func(e *event) {
+		e.prev.next = e.next
+		if e.next != nil {
+			e.next.prev = e.prev
+		}
+
+		m := e.match
+		m.prev.next = m.next
+
+		if m.next != nil {
+			m.next.prev = m.prev
+		}
+	}
+
+	unlift := func(e *event) {
+		m := e.match
+		m.prev.next = m
+
+		if m.next != nil {
+			m.next.prev = m
+		}
+
+		e.prev.next = e
+		if e.next != nil {
+			e.next.prev = e
+		}
+	}
+
+	type frame struct {
+		ev    *event
+		state *model
+	}
+
+	var (
+		stack   []frame
+		longest []operation
+	)
+
+	linearized := make([]bool, len(ops))
+	seen := make(map[string]struct{})
+	state := initial
+
+	for e := head.next; head.next != nil; {
+		if e.call {
+			next := state.clone()
+			ok := next.step(ops[e.op])
+
+			linearized[e.op] = true
+			k := fmt.Sprint(linearized) + next.key()
+
+			if _, explored := seen[k]; ok && !explored {
+				seen[k] = struct{}{}
+				stack = append(stack, frame{ev: e, state: state})
+				state = next
+
+				lift(e)
+
+				e = head.next
+
+				continue
+			}
+
+			linearized[e.op] = false
+			e = e.next
+
+			continue
+		}
+
+		// a return before its call was linearized: undo the last choice
+		if len(stack) > len(longest) {
+			longest = longest[:0]
+			for _, f := range stack {
+				longest = append(longest, ops[f.ev.op])
+			}
+		}
+
+		if len(stack) == 0 {
+			return false, longest
+		}
+
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		state = top.state
+		linearized[top.ev.op] = false
+
+		unlift(top.ev)
+
+		e = top.ev.next
+	}
+
+	return true, nil
+}
+
+func TestLinearizabilityChecker(t *testing.T) {
+	m := &model{entries: nil, hand: 0, capacity: 2, ttl: 0}
+
+	// Set(1) and Get(1) overlap, so the Get can see the value or not
+	ok, _ := linearizable([]operation{
+		{kind: opSet, key: 1, value: 10, call: 1, ret: 4},
+		{kind: opGet, key: 1, result: 10, ok: true, call: 2, ret: 3},
+		{kind: opGet, key: 1, result: 0, ok: false, call: 2, ret: 3},
+	}, m)
+	if !ok {
+		t.Errorf("expected overlapping operations to be linearizable")
+	}
+
+	// the Get starts after the Set returned, it must see the value
+	ok, prefix := linearizable([]operation{
+		{kind: opSet, key: 1, value: 10, call: 1, ret: 2},
+		{kind: opGet, key: 1, result: 0, ok: false, call: 3, ret: 4},
+	}, m)
+	if ok {
+		t.Errorf("expected a stale read to not be linearizable")
+	}
+
+	if len(prefix) != 1 || prefix[0].kind != opSet {
+		t.Errorf("expected the Set to be linearized, got %v", prefix)
+	}
+
+	// with a size of 2, the third key evicts the first one that was not read
+	ok, _ = linearizable([]operation{
+		{kind: opSet, key: 1, value: 1, call: 1, ret: 2},
+		{kind: opSet, key: 2, value: 2, call: 3, ret: 4},
+		{kind: opGet, key: 1, result: 1, ok: true, call: 5, ret: 6},
+		{kind: opSet, key: 3, value: 3, call: 7, ret: 8},
+		{kind: opGet, key: 1, result: 1, ok: true, call: 9, ret: 10},
+		{kind: opGet, key: 2, result: 2, ok: true, call: 9, ret: 10},
+	}, m)
+	if ok {
+		t.Errorf("expected the history to miss an eviction")
+	}
+}
+
+func TestLinearizability(t *testing.T) {
+	const (
+		clients = 4
+		ops     = 25
+	)
+
+	for round := range 20 {
+		s := New[int, int](3)
+		r := &recorder{clock: atomic.Int64{}, mu: sync.Mutex{}, ops: nil}
+
+		var wg sync.WaitGroup
+
+		for c := range clients {
+			rnd := rand.New(rand.NewPCG(uint64(round), uint64(c)))
+
+			wg.Go(func() {
+				for i := range ops {
+					op := operation{kind: opKind(rnd.IntN(3)), key: rnd.IntN(5), value: c*ops + i}
+					r.do(s, op)
+				}
+			})
+		}
+
+		wg.Wait()
+
+		if ok, prefix := linearizable(r.ops, &model{entries: nil, hand: 0, capacity: 3, ttl: 0}); !ok {
+			t.Fatalf("round %d: history is not linearizable, longest linearized prefix: %v", round, prefix)
+		}
+	}
+}
@@ -0,0 +1,529 @@
+package sieve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when writing to a LoadingCache after Close.
+var ErrClosed = errors.New("sieve: loading cache is closed")
+
+// WriteMode tells a LoadingCache how writes reach the store.
+type WriteMode int
+
+const (
+	// ReadThrough loads misses from the store, while Set and Delete only change the cache.
+	ReadThrough WriteMode = iota
+	// WriteThrough also writes to the store before changing the cache.
+	WriteThrough
+	// WriteBehind changes the cache right away and writes to the store in batches from a background goroutine.
+	WriteBehind
+)
+
+const (
+	defaultQueueSize     = 1024
+	defaultBatchSize     = 64
+	defaultFlushInterval = 100 * time.Millisecond
+
+	// maxWriteRetries is how many times a failed write is retried before it is dropped.
+	maxWriteRetries = 5
+	// maxRetryBackoff caps the wait before retrying a failed write.
+	maxRetryBackoff = 30 * time.Second
+	// maxWriteErrors caps the errors of the dropped writes kept for Close.
+	maxWriteErrors = 16
+)
+
+// LoadingCache is a Cache that reads from and writes to a Store.
+type LoadingCache[K comparable, V any] struct {
+	cache *Cache[K, V]
+	store Store[K, V]
+	mode  WriteMode
+
+	queueSize     int
+	batchSize     int
+	flushInterval time.Duration
+
+	// mu guards the write-behind state below
+	mu sync.Mutex
+	// pending is the write-behind queue, it holds the latest write of each key until it is saved
+	pending map[K]writeOp[K, V]
+	seq     uint64
+	// drained is closed, and replaced, every time writes leave the queue
+	drained chan struct{}
+	closed  bool
+	errs    []error
+	// moreErrs counts the errors past maxWriteErrors
+	moreErrs int
+
+	start sync.Once
+	kick  chan struct{}
+	done  chan struct{}
+}
+
+type writeOp[K comparable, V any] struct {
+	key    K
+	value  V
+	delete bool
+	seq    uint64
+
+	// attempts counts the failed saves, the write is not retried before retryAt
+	attempts int
+	retryAt  time.Time
+}
+
+// NewLoadingCache returns a LoadingCache that keeps the values of store in c.
+func NewLoadingCache[K comparable, V any](c *Cache[K, V], store Store[K, V], mode WriteMode) *LoadingCache[K, V] {
+	return &LoadingCache[K, V]{
+		cache:         c,
+		store:         store,
+		mode:          mode,
+		queueSize:     defaultQueueSize,
+		batchSize:     defaultBatchSize,
+		flushInterval: defaultFlushInterval,
+		mu:            sync.Mutex{},
+		pending:       make(map[K]writeOp[K, V]),
+		seq:           0,
+		drained:       make(chan struct{}),
+		closed:        false,
+		errs:          nil,
+		moreErrs:      0,
+		start:         sync.Once{},
+		kick:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+// WithWriteBehind is a builder function used to tune the WriteBehind mode.
+// At most queueSize writes wait to be saved, Set and Delete block when the queue is full.
+// The writes are saved once batchSize of them are queued, or after interval.
+// A failed write stays queued and is retried with an exponential backoff starting at interval,
+// it is dropped after 5 retries.
+func (l *LoadingCache[K, V]) WithWriteBehind(queueSize, batchSize int, interval time.Duration) *LoadingCache[K, V] {
+	l.queueSize = max(queueSize, 1)
+	l.batchSize = max(batchSize, 1)
+	l.flushInterval = interval
+
+	return l
+}
+
+// Cache returns the underlying cache.
+func (l *LoadingCache[K, V]) Cache() *Cache[K, V] {
+	return l.cache
+}
+
+// Get returns the value of the key, loading it from the store on a miss.
+// It returns ErrNotFound if the key is neither in the cache nor in the store.
+// With WithNegativeTTL on the cache, the keys missing from the store are remembered with SetNotFound.
+func (l *LoadingCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	switch v, res := l.cache.Lookup(key); res {
+	case Hit:
+		return v, nil
+	case Absent:
+		return v, ErrNotFound
+	case Miss:
+	}
+
+	if v, found, ok := l.lookupPending(key); ok {
+		if !found {
+			return v, ErrNotFound
+		}
+
+		l.cache.Set(key, v)
+
+		return v, nil
+	}
+
+	start := time.Now()
+	v, err := l.store.Load(ctx, key)
+	l.cache.stats.loads.observe(time.Since(start))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			l.fillNotFound([]K{key})
+		} else {
+			l.cache.logger.log("sieve: load failed", slog.Any("key", key), slog.Any("error", err))
+		}
+
+		return v, fmt.Errorf("sieve: load: %w", err)
+	}
+
+	return l.fill(key, v), nil
+}
+
+// GetMany returns the values of the keys that exist, loading all the misses with one call to the store.
+func (l *LoadingCache[K, V]) GetMany(ctx context.Context, keys []K) (map[K]V, error) {
+	values, misses := l.cache.GetMany(keys)
+
+	var missing []K
+
+	for _, k := range misses {
+		if v, found, ok := l.lookupPending(k); ok {
+			if found {
+				values[k] = v
+				l.cache.Set(k, v)
+			}
+
+			continue
+		}
+
+		missing = append(missing, k)
+	}
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	start := time.Now()
+	loaded, err := l.store.LoadMany(ctx, missing)
+	l.cache.stats.loads.observe(time.Since(start))
+	if err != nil {
+		l.cache.logger.log("sieve: load many failed", slog.Int("keys", len(missing)), slog.Any("error", err))
+
+		return values, fmt.Errorf("sieve: load many: %w", err)
+	}
+
+	l.fillMany(loaded)
+
+	for k, v := range loaded {
+		values[k] = v
+	}
+
+	notFound := make([]K, 0, len(missing)-len(loaded))
+
+	for _, k := range missing {
+		if _, ok := loaded[k]; !ok {
+			notFound = append(notFound, k)
+		}
+	}
+
+	l.fillNotFound(notFound)
+
+	return values, nil
+}
+
+// Set stores the value of the key, the store is written according to the WriteMode.
+func (l *LoadingCache[K, V]) Set(ctx context.Context, key K, value V) error {
+	switch l.mode {
+	case ReadThrough:
+	case WriteThrough:
+		if err := l.store.Save(ctx, key, value); err != nil {
+			return fmt.Errorf("sieve: save: %w", err)
+		}
+	case WriteBehind:
+		return l.enqueue(ctx, writeOp[K, V]{key: key, value: value, delete: false, seq: 0}, func() {
+			l.cache.Set(key, value)
+		})
+	}
+
+	l.cache.Set(key, value)
+
+	return nil
+}
+
+// Delete removes the key, the store is written according to the WriteMode.
+func (l *LoadingCache[K, V]) Delete(ctx context.Context, key K) error {
+	switch l.mode {
+	case ReadThrough:
+	case WriteThrough:
+		if err := l.store.Delete(ctx, key); err != nil {
+			return fmt.Errorf("sieve: delete: %w", err)
+		}
+	case WriteBehind:
+		var zeroValue V
+
+		return l.enqueue(ctx, writeOp[K, V]{key: key, value: zeroValue, delete: true, seq: 0}, func() {
+			l.cache.Delete(key)
+		})
+	}
+
+	l.cache.Delete(key)
+
+	return nil
+}
+
+// Close saves the queued writes and stops the background goroutine, retrying the failed ones without waiting.
+// It returns the errors of the writes dropped in WriteBehind mode, the first 16 of them.
+func (l *LoadingCache[K, V]) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+
+		return ErrClosed
+	}
+
+	l.closed = true
+	l.mu.Unlock()
+
+	// make sure the worker runs the last flush, even if nothing was written
+	l.startWorker()
+	l.wake()
+
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	errs := l.errs
+	if l.moreErrs > 0 {
+		errs = append(errs, fmt.Errorf("sieve: write behind: %d more writes dropped", l.moreErrs))
+	}
+
+	return errors.Join(errs...)
+}
+
+// fill caches a value loaded from the store and returns it,
+// unless a write queued in the meantime made it stale.
+func (l *LoadingCache[K, V]) fill(key K, v V) V {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if op, ok := l.pending[key]; ok {
+		if !op.delete {
+			return op.value
+		}
+
+		// the key is being deleted, don't bring it back
+		return v
+	}
+
+	l.cache.Set(key, v)
+
+	return v
+}
+
+// fillMany caches the values loaded from the store, replacing in place the stale ones.
+func (l *LoadingCache[K, V]) fillMany(loaded map[K]V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fresh := make(map[K]V, len(loaded))
+
+	for k, v := range loaded {
+		if op, ok := l.pending[k]; ok {
+			if !op.delete {
+				loaded[k] = op.value
+			}
+
+			continue
+		}
+
+		fresh[k] = v
+	}
+
+	l.cache.SetMany(fresh)
+}
+
+// fillNotFound remembers the keys missing from the store, if the cache has negative caching.
+func (l *LoadingCache[K, V]) fillNotFound(keys []K) {
+	if l.cache.negativeTTL <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, k := range keys {
+		// a queued save is about to create the key
+		if op, ok := l.pending[k]; ok && !op.delete {
+			continue
+		}
+
+		l.cache.SetNotFound(k)
+	}
+}
+
+// lookupPending returns the queued write of the key, ok is false if there is none.
+func (l *LoadingCache[K, V]) lookupPending(key K) (V, bool, bool) {
+	if l.mode != WriteBehind {
+		var zeroValue V
+
+		return zeroValue, false, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	op, ok := l.pending[key]
+
+	return op.value, !op.delete, ok
+}
+
+// enqueue applies the write to the cache and queues it for the store,
+// waiting for room if the queue is full.
+func (l *LoadingCache[K, V]) enqueue(ctx context.Context, op writeOp[K, V], apply func()) error {
+	l.startWorker()
+
+	l.mu.Lock()
+
+	for {
+		if l.closed {
+			l.mu.Unlock()
+
+			return ErrClosed
+		}
+
+		// a key already queued is overwritten, so it takes no room
+		if _, ok := l.pending[op.key]; ok || len(l.pending) < l.queueSize {
+			break
+		}
+
+		drained := l.drained
+		l.mu.Unlock()
+
+		l.wake()
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return fmt.Errorf("sieve: write behind: %w", ctx.Err())
+		}
+
+		l.mu.Lock()
+	}
+
+	// the cache and the queue change together, so a miss never loads an older value from the store
+	l.seq++
+	op.seq = l.seq
+	l.pending[op.key] = op
+
+	apply()
+
+	full := len(l.pending) >= l.batchSize
+
+	l.mu.Unlock()
+
+	if full {
+		l.wake()
+	}
+
+	return nil
+}
+
+func (l *LoadingCache[K, V]) startWorker() {
+	l.start.Do(func() {
+		go l.worker()
+	})
+}
+
+// wake asks the worker to flush without waiting for the interval.
+func (l *LoadingCache[K, V]) wake() {
+	select {
+	case l.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (l *LoadingCache[K, V]) worker() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(max(l.flushInterval, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.kick:
+		case <-ticker.C:
+		}
+
+		for l.flush() {
+		}
+
+		l.mu.Lock()
+		closed := l.closed && len(l.pending) == 0
+		l.mu.Unlock()
+
+		if closed {
+			return
+		}
+	}
+}
+
+// flush saves one batch of queued writes, it returns true if it should be called again right away:
+// more than a batch was ready to be saved, or Close is waiting for failed writes.
+func (l *LoadingCache[K, V]) flush() bool {
+	l.mu.Lock()
+
+	now := time.Now()
+	batch := make([]writeOp[K, V], 0, min(len(l.pending), l.batchSize))
+	ready := 0
+
+	for _, op := range l.pending {
+		// a failed write waits for its backoff, unless Close is waiting for it
+		if !l.closed && op.retryAt.After(now) {
+			continue
+		}
+
+		ready++
+
+		if len(batch) < l.batchSize {
+			batch = append(batch, op)
+		}
+	}
+
+	more := ready > len(batch)
+
+	l.mu.Unlock()
+
+	if len(batch) == 0 {
+		return false
+	}
+
+	ctx := context.Background()
+
+	errs := make([]error, len(batch))
+
+	// writes stay in the queue while they are saved, so a miss still finds them
+	for i, op := range batch {
+		if op.delete {
+			errs[i] = l.store.Delete(ctx, op.key)
+		} else {
+			errs[i] = l.store.Save(ctx, op.key, op.value)
+		}
+
+		if errs[i] != nil {
+			l.cache.logger.log("sieve: write behind failed",
+				slog.Any("key", op.key), slog.Int("attempt", op.attempts+1), slog.Any("error", errs[i]))
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, op := range batch {
+		// a newer write of the same key is saved in a later batch
+		if l.pending[op.key].seq != op.seq {
+			continue
+		}
+
+		if errs[i] == nil {
+			delete(l.pending, op.key)
+
+			continue
+		}
+
+		op.attempts++
+
+		if op.attempts <= maxWriteRetries {
+			op.retryAt = now.Add(min(max(l.flushInterval, time.Millisecond)<<(op.attempts-1), maxRetryBackoff))
+			l.pending[op.key] = op
+
+			continue
+		}
+
+		delete(l.pending, op.key)
+
+		if len(l.errs) < maxWriteErrors {
+			l.errs = append(l.errs, fmt.Errorf("sieve: write behind: dropped after %d attempts: %w", op.attempts, errs[i]))
+		} else {
+			l.moreErrs++
+		}
+	}
+
+	close(l.drained)
+	l.drained = make(chan struct{})
+
+	return more || (l.closed && len(l.pending) > 0)
+}
@@ -0,0 +1,610 @@
+package sieve_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// countingStore counts the calls to the wrapped store.
+type countingStore struct {
+	*sieve.MemoryStore[int, string]
+
+	loads     atomic.Int32
+	loadManys atomic.Int32
+	saves     atomic.Int32
+	fail      atomic.Bool
+	// failNext makes the next saves fail
+	failNext atomic.Int32
+	// block, if set, makes every Save wait on it
+	block chan struct{}
+	// failLoads makes Load and LoadMany fail, failDeletes Delete
+	failLoads   atomic.Bool
+	failDeletes atomic.Bool
+	// onLoad, if set, runs in Load and LoadMany before the store is read
+	onLoad func()
+}
+
+var errBackend = errors.New("backend is down")
+
+func newCountingStore() *countingStore {
+	return &countingStore{MemoryStore: sieve.NewMemoryStore[int, string]()}
+}
+
+func (s *countingStore) Load(ctx context.Context, key int) (string, error) {
+	s.loads.Add(1)
+
+	if s.onLoad != nil {
+		s.onLoad()
+	}
+
+	if s.failLoads.Load() {
+		return "", errBackend
+	}
+
+	return s.MemoryStore.Load(ctx, key)
+}
+
+func (s *countingStore) LoadMany(ctx context.Context, keys []int) (map[int]string, error) {
+	s.loadManys.Add(1)
+
+	if s.onLoad != nil {
+		s.onLoad()
+	}
+
+	if s.failLoads.Load() {
+		return nil, errBackend
+	}
+
+	return s.MemoryStore.LoadMany(ctx, keys)
+}
+
+func (s *countingStore) Delete(ctx context.Context, key int) error {
+	if s.failDeletes.Load() {
+		return errBackend
+	}
+
+	return s.MemoryStore.Delete(ctx, key)
+}
+
+func (s *countingStore) Save(ctx context.Context, key int, value string) error {
+	s.saves.Add(1)
+
+	if s.block != nil {
+		<-s.block
+	}
+
+	if s.fail.Load() || s.failNext.Add(-1) >= 0 {
+		return errBackend
+	}
+
+	return s.MemoryStore.Save(ctx, key, value)
+}
+
+func TestReadThrough(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.MemoryStore.Save(ctx, 1, one)
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.ReadThrough)
+
+	for range 3 {
+		v, err := l.Get(ctx, 1)
+		if err != nil || v != one {
+			t.Errorf("expected 'one', got '%s' with %v", v, err)
+		}
+	}
+
+	if store.loads.Load() != 1 {
+		t.Errorf("expected 1 load, got %d", store.loads.Load())
+	}
+
+	if _, err := l.Get(ctx, 2); !errors.Is(err, sieve.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// writes only touch the cache
+	l.Set(ctx, 3, "three")
+	l.Delete(ctx, 1)
+
+	if store.Len() != 1 || l.Cache().Contains(1) {
+		t.Errorf("expected the store to be untouched and 1 to leave the cache")
+	}
+}
+
+func TestGetManyLoading(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+
+	for i, v := range []string{"zero", one, "two", "three"} {
+		store.MemoryStore.Save(ctx, i, v)
+	}
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.ReadThrough)
+	l.Get(ctx, 0)
+
+	values, err := l.GetMany(ctx, []int{0, 1, 2, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 3 || values[0] != "zero" || values[2] != "two" {
+		t.Errorf("expected 3 values, got %v", values)
+	}
+
+	if store.loadManys.Load() != 1 {
+		t.Errorf("expected 1 LoadMany, got %d", store.loadManys.Load())
+	}
+
+	if !l.Cache().Contains(1) || !l.Cache().Contains(2) {
+		t.Errorf("expected the loaded values to be cached")
+	}
+}
+
+func TestWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteThrough)
+
+	if err := l.Set(ctx, 1, one); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := store.MemoryStore.Load(ctx, 1); v != one {
+		t.Errorf("expected 'one' in the store, got '%s'", v)
+	}
+
+	store.fail.Store(true)
+
+	if err := l.Set(ctx, 1, "uno"); !errors.Is(err, errBackend) {
+		t.Errorf("expected the backend error, got %v", err)
+	}
+
+	// a failed save leaves the cache as the store
+	if v, _ := l.Get(ctx, 1); v != one {
+		t.Errorf("expected 'one', got '%s'", v)
+	}
+
+	if err := l.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.Len() != 0 || l.Cache().Contains(1) {
+		t.Errorf("expected 1 to be deleted from both")
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("expected no error on close, got %v", err)
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](2), store, sieve.WriteBehind).WithWriteBehind(100, 10, time.Hour)
+
+	for i := range 5 {
+		l.Set(ctx, 1, "v")
+		l.Set(ctx, i+10, "v")
+	}
+
+	l.Delete(ctx, 10)
+
+	// the cache is updated right away, the store is not
+	if v, err := l.Get(ctx, 1); err != nil || v != "v" {
+		t.Errorf("expected 'v', got '%s' with %v", v, err)
+	}
+
+	if store.Len() != 0 {
+		t.Errorf("expected nothing saved yet, got %d keys", store.Len())
+	}
+
+	// 11 was evicted from the cache but is still queued, the store is not asked
+	if v, err := l.Get(ctx, 11); err != nil || v != "v" {
+		t.Errorf("expected queued value for 11, got '%s' with %v", v, err)
+	}
+
+	if _, err := l.Get(ctx, 10); !errors.Is(err, sieve.ErrNotFound) {
+		t.Errorf("expected queued delete for 10, got %v", err)
+	}
+
+	if store.loads.Load() != 0 {
+		t.Errorf("expected no loads, got %d", store.loads.Load())
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the latest write of each key is saved
+	if store.Len() != 5 || store.saves.Load() != 5 {
+		t.Errorf("expected 5 keys with 5 saves, got %d keys with %d saves", store.Len(), store.saves.Load())
+	}
+
+	if err := l.Set(ctx, 1, "late"); !errors.Is(err, sieve.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWriteBehindBatch(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteBehind).WithWriteBehind(100, 3, time.Hour)
+	defer l.Close()
+
+	for i := range 3 {
+		l.Set(ctx, i, "v")
+	}
+
+	// a full batch is saved without waiting for the interval
+	deadline := time.Now().Add(time.Second)
+	for store.Len() != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if store.Len() != 3 {
+		t.Errorf("expected the batch to be saved, got %d keys", store.Len())
+	}
+}
+
+func TestWriteBehindBoundedQueue(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.block = make(chan struct{})
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteBehind).WithWriteBehind(2, 1, time.Millisecond)
+
+	l.Set(ctx, 1, "v")
+	l.Set(ctx, 2, "v")
+
+	// the queue is full and the store is stuck
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	if err := l.Set(timeout, 3, "v"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the full queue to block, got %v", err)
+	}
+
+	// overwriting a queued key takes no room
+	if err := l.Set(timeout, 2, "w"); err != nil {
+		t.Errorf("expected overwrite to succeed, got %v", err)
+	}
+
+	close(store.block)
+
+	if err := l.Set(ctx, 3, "v"); err != nil {
+		t.Errorf("expected room after the store recovered, got %v", err)
+	}
+
+	l.Close()
+
+	if v, _ := store.MemoryStore.Load(ctx, 2); v != "w" {
+		t.Errorf("expected the latest value of 2, got '%s'", v)
+	}
+}
+
+func TestWriteBehindErrors(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.fail.Store(true)
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteBehind)
+	l.Set(ctx, 1, "v")
+
+	if err := l.Close(); !errors.Is(err, errBackend) {
+		t.Errorf("expected the backend error on close, got %v", err)
+	}
+
+	if err := l.Close(); !errors.Is(err, sieve.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.failNext.Store(1)
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteBehind).WithWriteBehind(100, 1, time.Millisecond)
+	l.Set(ctx, 1, "v")
+
+	// the failed save is retried after the backoff, without waiting for Close
+	deadline := time.Now().Add(time.Second)
+	for store.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if v, _ := store.MemoryStore.Load(ctx, 1); v != "v" {
+		t.Errorf("expected the write to be retried, got '%s' after %d saves", v, store.saves.Load())
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("expected no error once the retry succeeded, got %v", err)
+	}
+
+	if store.saves.Load() != 2 {
+		t.Errorf("expected 2 saves, got %d", store.saves.Load())
+	}
+}
+
+func TestWriteBehindDropped(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.fail.Store(true)
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](100), store, sieve.WriteBehind).WithWriteBehind(100, 10, time.Hour)
+
+	for i := range 20 {
+		l.Set(ctx, i, "v")
+	}
+
+	err := l.Close()
+	if !errors.Is(err, errBackend) {
+		t.Fatalf("expected the backend error on close, got %v", err)
+	}
+
+	// every write was tried once and retried 5 times, only the first errors are kept
+	if store.saves.Load() != 20*6 {
+		t.Errorf("expected 120 saves, got %d", store.saves.Load())
+	}
+
+	if !strings.Contains(err.Error(), "4 more writes dropped") {
+		t.Errorf("expected the errors to be capped, got %v", err)
+	}
+}
+
+func TestWriteBehindConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](8), store, sieve.WriteBehind).WithWriteBehind(4, 2, time.Millisecond)
+
+	var wg sync.WaitGroup
+
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 100 {
+				l.Set(ctx, i%16, "v")
+				l.Get(ctx, (i+g)%16)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.Len() != 16 {
+		t.Errorf("expected 16 keys saved, got %d", store.Len())
+	}
+}
+
+func TestLoadingNegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.MemoryStore.Save(ctx, 1, one)
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10).WithNegativeTTL(time.Minute), store, sieve.ReadThrough)
+
+	for range 3 {
+		if _, err := l.Get(ctx, 2); !errors.Is(err, sieve.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	}
+
+	if store.loads.Load() != 1 {
+		t.Errorf("expected 1 load, got %d", store.loads.Load())
+	}
+
+	values, _ := l.GetMany(ctx, []int{1, 2, 3})
+	values, _ = l.GetMany(ctx, []int{1, 2, 3})
+
+	if len(values) != 1 || store.loadManys.Load() != 1 {
+		t.Errorf("expected 1 value and 1 LoadMany, got %v and %d", values, store.loadManys.Load())
+	}
+
+	// a write replaces the negative entry
+	l.Set(ctx, 2, "two")
+
+	if v, err := l.Get(ctx, 2); err != nil || v != "two" {
+		t.Errorf("expected 'two', got '%s' with %v", v, err)
+	}
+}
+
+func TestLoadingErrors(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.MemoryStore.Save(ctx, 1, one)
+	store.failLoads.Store(true)
+	store.failDeletes.Store(true)
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteThrough)
+
+	if _, err := l.Get(ctx, 1); !errors.Is(err, errBackend) || errors.Is(err, sieve.ErrNotFound) {
+		t.Errorf("expected the backend error, got %v", err)
+	}
+
+	if values, err := l.GetMany(ctx, []int{1, 2}); !errors.Is(err, errBackend) || len(values) != 0 {
+		t.Errorf("expected the backend error and no values, got %v and %v", values, err)
+	}
+
+	// nothing was cached from a failed load
+	if l.Cache().Len() != 0 {
+		t.Errorf("expected an empty cache, got %d keys", l.Cache().Len())
+	}
+
+	l.Cache().Set(1, one)
+
+	// a failed delete leaves the cache as the store
+	if err := l.Delete(ctx, 1); !errors.Is(err, errBackend) {
+		t.Errorf("expected the backend error, got %v", err)
+	}
+
+	if !l.Cache().Contains(1) {
+		t.Errorf("expected 1 to stay cached")
+	}
+}
+
+func TestWriteBehindDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+
+	for i, v := range []string{"zero", one, "two", "three"} {
+		store.MemoryStore.Save(ctx, i, v)
+	}
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10).WithNegativeTTL(time.Minute), store, sieve.WriteBehind).
+		WithWriteBehind(100, 100, time.Hour)
+	defer l.Close()
+
+	// the writes queued while the store is read are newer than what it returns
+	store.onLoad = func() {
+		l.Set(ctx, 0, "new zero")
+		l.Set(ctx, 9, "nine")
+
+		// only once, the next loads see the queue before they start
+		store.onLoad = nil
+	}
+
+	if v, err := l.Get(ctx, 0); err != nil || v != "new zero" {
+		t.Errorf("expected the queued value, got '%s' with %v", v, err)
+	}
+
+	if v, _ := l.Cache().Peek(0); v != "new zero" {
+		t.Errorf("expected the queued value to stay cached, got '%s'", v)
+	}
+
+	store.onLoad = func() {
+		l.Delete(ctx, 1)
+
+		store.onLoad = nil
+	}
+
+	// the value read before the delete is returned, but not cached
+	if v, err := l.Get(ctx, 1); err != nil || v != one || l.Cache().Contains(1) {
+		t.Errorf("expected 'one' without caching it, got '%s' with %v", v, err)
+	}
+
+	store.onLoad = func() {
+		l.Set(ctx, 2, "new two")
+		l.Delete(ctx, 3)
+		l.Set(ctx, 8, "eight")
+
+		store.onLoad = nil
+	}
+
+	// 0 is cached, 1 and 9 are queued, the others are loaded while the queue changes
+	values, err := l.GetMany(ctx, []int{0, 1, 2, 3, 8, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int]string{0: "new zero", 2: "new two", 3: "three", 9: "nine"}
+	if !maps.Equal(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	// the deleted keys are not brought back
+	if l.Cache().Contains(1) || l.Cache().Contains(3) {
+		t.Errorf("expected the deleted keys to stay out of the cache")
+	}
+
+	if v, _ := l.Cache().Peek(8); v != "eight" {
+		t.Errorf("expected the queued save of 8 to be cached, got '%s'", v)
+	}
+}
+
+func TestWriteBehindQueuedMiss(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](2), store, sieve.WriteBehind).WithWriteBehind(100, 100, time.Hour)
+	defer l.Close()
+
+	l.Set(ctx, 1, one)
+
+	// 1 leaves the cache while its save is still queued
+	l.Cache().Set(2, "two")
+	l.Cache().Set(3, "three")
+
+	values, err := l.GetMany(ctx, []int{1})
+	if err != nil || values[1] != one {
+		t.Errorf("expected the queued value, got %v with %v", values, err)
+	}
+
+	if store.loadManys.Load() != 0 {
+		t.Errorf("expected no load, got %d", store.loadManys.Load())
+	}
+}
+
+func TestWriteBehindBackoffSkipped(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.failNext.Store(1)
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteBehind).WithWriteBehind(100, 1, time.Hour)
+
+	l.Set(ctx, 1, one)
+
+	for store.saves.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 1 waits for its backoff, the batches in the meantime save the other keys
+	l.Set(ctx, 2, "two")
+
+	deadline := time.Now().Add(time.Second)
+	for store.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if v, _ := store.MemoryStore.Load(ctx, 2); v != "two" {
+		t.Errorf("expected 2 to be saved while 1 waits, got '%s'", v)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := store.MemoryStore.Load(ctx, 1); v != one {
+		t.Errorf("expected Close to retry 1, got '%s'", v)
+	}
+}
+
+func TestWriteBehindNewerWrite(t *testing.T) {
+	ctx := context.Background()
+	store := newCountingStore()
+	store.block = make(chan struct{})
+
+	l := sieve.NewLoadingCache(sieve.New[int, string](10), store, sieve.WriteBehind).WithWriteBehind(100, 1, time.Hour)
+
+	l.Set(ctx, 1, one)
+
+	for store.saves.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// written while the first value is being saved, it must not be taken off the queue with it
+	l.Set(ctx, 1, "uno")
+	close(store.block)
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := store.MemoryStore.Load(ctx, 1); v != "uno" || store.saves.Load() != 2 {
+		t.Errorf("expected the newer value saved after the first, got '%s' after %d saves", v, store.saves.Load())
+	}
+}
@@ -0,0 +1,69 @@
+package sieve
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// logInterval is the minimum time between two logs of the same event.
+const logInterval = 10 * time.Second
+
+// WithLogger is a builder function used to log the rare events of the sieve:
+// long hand sweeps, failed loads, refreshes and write-behind saves.
+// Each event is logged at most once every 10 seconds, with the number of the ones skipped meanwhile.
+func (s *Cache[K, V]) WithLogger(logger *slog.Logger) *Cache[K, V] {
+	s.logger = newEventLogger(logger)
+
+	return s
+}
+
+// eventLogger rate limits the logs of each event, the event is the log message.
+type eventLogger struct {
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	last       map[string]time.Time
+	suppressed map[string]int
+}
+
+func newEventLogger(logger *slog.Logger) *eventLogger {
+	return &eventLogger{
+		logger:     logger,
+		mu:         sync.Mutex{},
+		last:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// log logs the event at warning level, unless the same event was logged less than logInterval ago.
+// It does nothing on a nil logger, so the sieve can call it without checking.
+func (l *eventLogger) log(msg string, attrs ...slog.Attr) {
+	if l == nil {
+		return
+	}
+
+	atNow := now()
+
+	l.mu.Lock()
+
+	if last, ok := l.last[msg]; ok && atNow.Sub(last) < logInterval {
+		l.suppressed[msg]++
+		l.mu.Unlock()
+
+		return
+	}
+
+	suppressed := l.suppressed[msg]
+	l.last[msg] = atNow
+	delete(l.suppressed, msg)
+
+	l.mu.Unlock()
+
+	if suppressed > 0 {
+		attrs = append(attrs, slog.Int("suppressed", suppressed))
+	}
+
+	l.logger.LogAttrs(context.Background(), slog.LevelWarn, msg, attrs...)
+}
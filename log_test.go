@@ -0,0 +1,85 @@
+package sieve
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLongSweep(t *testing.T) {
+	var buf bytes.Buffer
+
+	s := New[int, string](4).WithLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	visitAll := func(keys ...int) {
+		for _, k := range keys {
+			s.Set(k, "v")
+			s.Get(k)
+		}
+	}
+
+	visitAll(1, 2, 3, 4)
+	s.Set(5, "v")
+
+	if !strings.Contains(buf.String(), `msg="sieve: long hand sweep" cleared=4 len=4`) {
+		t.Errorf("expected a long sweep log, got %s", buf.String())
+	}
+
+	// the same event is not logged again before the interval
+	buf.Reset()
+
+	visitAll(2, 3, 4, 5)
+	s.Set(6, "v")
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no log, got %s", buf.String())
+	}
+
+	sec += 11
+
+	visitAll(3, 4, 5, 6)
+	s.Set(7, "v")
+
+	if !strings.Contains(buf.String(), "suppressed=1") {
+		t.Errorf("expected a log with the suppressed count, got %s", buf.String())
+	}
+}
+
+func TestLogRefreshFailed(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := newRefresher()
+	r.fail.Store(true)
+	close(r.release)
+
+	s := New[int, string](4).
+		WithStaleWhileRevalidate(2*time.Second, 5*time.Second, r.refresh).
+		WithLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(7, "original")
+
+	sec = 4
+
+	s.Get(7)
+	waitRefreshDone(s, 7)
+
+	if !strings.Contains(buf.String(), `msg="sieve: refresh failed" key=7 error="backend is down"`) {
+		t.Errorf("expected a refresh failure log, got %s", buf.String())
+	}
+}
+
+func TestLogNil(t *testing.T) {
+	var l *eventLogger
+
+	// nothing to log to, nothing happens
+	l.log("sieve: nothing", slog.Any("error", errors.New("boom")))
+}
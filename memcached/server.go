@@ -0,0 +1,534 @@
+// Package memcached serves a sieve cache over the memcached text protocol.
+//
+// The supported commands are get, gets, set, add, replace, cas, delete, touch,
+// flush_all, stats, version and quit.
+package memcached
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// Item is the value stored in the cache for each key.
+// Items are never modified once stored, every write stores a new one.
+type Item struct {
+	Flags uint32
+	Value []byte
+	// CAS is the unique token returned by gets and checked by cas.
+	CAS uint64
+}
+
+const (
+	// maxKeyLen is the longest key accepted by memcached.
+	maxKeyLen = 250
+	// maxItemSize is the default item size limit of memcached.
+	maxItemSize = 1 << 20
+	// relativeExpLimit is the largest exptime that is relative to now, bigger values are unix timestamps.
+	relativeExpLimit = 60 * 60 * 24 * 30
+
+	version = "1.6.0-sieve"
+)
+
+var errBadFormat = errors.New("bad command line format")
+
+// Server answers memcached clients from a sieve.
+type Server struct {
+	cache *sieve.Cache[string, *Item]
+
+	// writeMu makes the read-check-write commands atomic
+	writeMu sync.Mutex
+	cas     atomic.Uint64
+	started time.Time
+	// flushAt is the deadline of a delayed flush_all in unix nanoseconds, zero when none is pending.
+	flushAt atomic.Int64
+
+	cmdGet   atomic.Uint64
+	cmdSet   atomic.Uint64
+	cmdTouch atomic.Uint64
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer returns a server backed by cache.
+func NewServer(cache *sieve.Cache[string, *Item]) *Server {
+	return &Server{
+		cache:     cache,
+		writeMu:   sync.Mutex{},
+		cas:       atomic.Uint64{},
+		started:   now(),
+		flushAt:   atomic.Int64{},
+		cmdGet:    atomic.Uint64{},
+		cmdSet:    atomic.Uint64{},
+		cmdTouch:  atomic.Uint64{},
+		mu:        sync.Mutex{},
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		closed:    false,
+		wg:        sync.WaitGroup{},
+	}
+}
+
+// Serve accepts connections on l until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return net.ErrClosed
+	}
+
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return nil
+			}
+
+			return fmt.Errorf("memcached: accept: %w", err)
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+
+			return nil
+		}
+
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+
+			s.ServeConn(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops the listeners and drops the open connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+
+	for l := range s.listeners {
+		l.Close()
+	}
+
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return nil
+}
+
+// ServeConn answers the commands read from rw until the client quits or the connection fails.
+func (s *Server) ServeConn(rw io.ReadWriteCloser) {
+	defer rw.Close()
+
+	r := bufio.NewReader(rw)
+	w := bufio.NewWriter(rw)
+
+	for {
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				w.WriteString("CLIENT_ERROR line too long\r\n")
+				w.Flush()
+			}
+
+			return
+		}
+
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			w.WriteString("ERROR\r\n")
+		} else if quit := s.exec(r, w, fields); quit {
+			w.Flush()
+
+			return
+		}
+
+		// reply to pipelined commands in one write
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exec runs a command and writes its reply, it returns true if the connection must be closed.
+func (s *Server) exec(r *bufio.Reader, w *bufio.Writer, fields []string) bool {
+	var (
+		reply string
+		err   error
+	)
+
+	s.flushIfDue()
+
+	switch cmd, args := fields[0], fields[1:]; cmd {
+	case "get", "gets":
+		err = s.get(w, args, cmd == "gets")
+	case "set", "add", "replace", "cas":
+		reply, err = s.store(r, cmd, args)
+	case "delete":
+		reply, err = s.delete(args)
+	case "touch":
+		reply, err = s.touch(args)
+	case "flush_all":
+		reply, err = s.flushAll(args)
+	case "stats":
+		s.stats(w)
+	case "version":
+		reply = "VERSION " + version
+	case "quit":
+		return true
+	default:
+		reply = "ERROR"
+	}
+
+	var ce clientError
+
+	switch {
+	case errors.As(err, &ce):
+		reply = "CLIENT_ERROR " + string(ce)
+	case errors.Is(err, errBadFormat):
+		reply = "CLIENT_ERROR " + err.Error()
+	case err != nil:
+		// the connection is broken
+		return true
+	}
+
+	if reply != "" && !noreply(fields) {
+		w.WriteString(reply)
+		w.WriteString("\r\n")
+	}
+
+	return false
+}
+
+type clientError string
+
+func (e clientError) Error() string {
+	return string(e)
+}
+
+func noreply(fields []string) bool {
+	return fields[len(fields)-1] == "noreply"
+}
+
+func (s *Server) get(w *bufio.Writer, keys []string, withCAS bool) error {
+	if len(keys) == 0 {
+		return errBadFormat
+	}
+
+	for _, key := range keys {
+		s.cmdGet.Add(1)
+
+		it, ok := s.cache.Get(key)
+		if !ok {
+			continue
+		}
+
+		if withCAS {
+			fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.Flags, len(it.Value), it.CAS)
+		} else {
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.Flags, len(it.Value))
+		}
+
+		w.Write(it.Value)
+		w.WriteString("\r\n")
+	}
+
+	w.WriteString("END\r\n")
+
+	return nil
+}
+
+// store handles <cmd> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply].
+func (s *Server) store(r *bufio.Reader, cmd string, args []string) (string, error) {
+	want := 4
+	if cmd == "cas" {
+		want = 5
+	}
+
+	if len(args) != want && (len(args) != want+1 || args[want] != "noreply") {
+		return "", errBadFormat
+	}
+
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	size, err3 := strconv.Atoi(args[3])
+
+	if err := errors.Join(err1, err2, err3); err != nil || size < 0 || !validKey(args[0]) {
+		return "", errBadFormat
+	}
+
+	var casUnique uint64
+
+	if cmd == "cas" {
+		v, err := strconv.ParseUint(args[4], 10, 64)
+		if err != nil {
+			return "", errBadFormat
+		}
+
+		casUnique = v
+	}
+
+	if size > maxItemSize {
+		// swallow the data block, so the next command is read correctly
+		if _, err := r.Discard(size + 2); err != nil {
+			return "", fmt.Errorf("memcached: read data: %w", err)
+		}
+
+		return "SERVER_ERROR object too large for cache", nil
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", fmt.Errorf("memcached: read data: %w", err)
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		// drop what is left of the oversized data block
+		if data[size+1] != '\n' {
+			if _, err := r.ReadSlice('\n'); err != nil {
+				return "", fmt.Errorf("memcached: read data: %w", err)
+			}
+		}
+
+		return "", clientError("bad data chunk")
+	}
+
+	s.cmdSet.Add(1)
+
+	key := args[0]
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	old, exists := s.cache.Peek(key)
+
+	switch cmd {
+	case "add":
+		if exists {
+			return "NOT_STORED", nil
+		}
+	case "replace":
+		if !exists {
+			return "NOT_STORED", nil
+		}
+	case "cas":
+		if !exists {
+			return "NOT_FOUND", nil
+		}
+
+		if old.CAS != casUnique {
+			return "EXISTS", nil
+		}
+	}
+
+	it := &Item{Flags: uint32(flags), Value: data[:size], CAS: s.cas.Add(1)}
+
+	ttl, alive := expiration(exptime)
+	if !alive {
+		// memcached accepts the item and makes it expire right away
+		s.cache.Delete(key)
+
+		return "STORED", nil
+	}
+
+	s.cache.SetWithTTL(key, it, ttl)
+
+	return "STORED", nil
+}
+
+// delete handles delete <key> [noreply].
+func (s *Server) delete(args []string) (string, error) {
+	if len(args) != 1 && (len(args) != 2 || args[1] != "noreply") {
+		return "", errBadFormat
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if s.cache.Delete(args[0]) {
+		return "DELETED", nil
+	}
+
+	return "NOT_FOUND", nil
+}
+
+// touch handles touch <key> <exptime> [noreply].
+func (s *Server) touch(args []string) (string, error) {
+	if len(args) != 2 && (len(args) != 3 || args[2] != "noreply") {
+		return "", errBadFormat
+	}
+
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "", errBadFormat
+	}
+
+	s.cmdTouch.Add(1)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	it, ok := s.cache.Peek(args[0])
+	if !ok {
+		return "NOT_FOUND", nil
+	}
+
+	ttl, alive := expiration(exptime)
+	if !alive {
+		s.cache.Delete(args[0])
+
+		return "TOUCHED", nil
+	}
+
+	// storing the same item keeps its CAS token and only moves the deadline
+	s.cache.SetWithTTL(args[0], it, ttl)
+
+	return "TOUCHED", nil
+}
+
+// flushAll handles flush_all [delay] [noreply].
+func (s *Server) flushAll(args []string) (string, error) {
+	if len(args) > 0 && args[len(args)-1] == "noreply" {
+		args = args[:len(args)-1]
+	}
+
+	if len(args) > 1 {
+		return "", errBadFormat
+	}
+
+	if len(args) == 0 {
+		s.flushAt.Store(0)
+		s.cache.Flush()
+
+		return "OK", nil
+	}
+
+	delay, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || delay < 0 {
+		return "", errBadFormat
+	}
+
+	// a new delay replaces the pending one
+	if ttl, alive := expiration(delay); alive && ttl > 0 {
+		s.flushAt.Store(now().Add(ttl).UnixNano())
+	} else {
+		s.flushAt.Store(0)
+		s.cache.Flush()
+	}
+
+	return "OK", nil
+}
+
+// flushIfDue runs the delayed flush_all once its deadline has passed, before the next command sees the cache.
+func (s *Server) flushIfDue() {
+	at := s.flushAt.Load()
+	if at == 0 || now().UnixNano() < at {
+		return
+	}
+
+	if s.flushAt.CompareAndSwap(at, 0) {
+		s.cache.Flush()
+	}
+}
+
+func (s *Server) stats(w *bufio.Writer) {
+	st := s.cache.Stats()
+	at := now()
+
+	s.mu.Lock()
+	conns := len(s.conns)
+	s.mu.Unlock()
+
+	stat := func(name string, value any) {
+		fmt.Fprintf(w, "STAT %s %v\r\n", name, value)
+	}
+
+	stat("pid", os.Getpid())
+	stat("uptime", int64(at.Sub(s.started)/time.Second))
+	stat("time", at.Unix())
+	stat("version", version)
+	stat("curr_connections", conns)
+	stat("cmd_get", s.cmdGet.Load())
+	stat("cmd_set", s.cmdSet.Load())
+	stat("cmd_touch", s.cmdTouch.Load())
+	stat("get_hits", st.Hits)
+	stat("get_misses", st.Misses)
+	stat("get_expired", st.Expirations)
+	stat("curr_items", st.Len)
+	stat("limit_items", st.Capacity)
+	stat("evictions", st.Evictions)
+	w.WriteString("END\r\n")
+}
+
+// expiration converts a memcached exptime into a TTL, zero meaning no deadline.
+// It returns false if the item is already expired.
+func expiration(exptime int64) (time.Duration, bool) {
+	switch {
+	case exptime == 0:
+		return 0, true
+	case exptime < 0:
+		return 0, false
+	case exptime <= relativeExpLimit:
+		return time.Duration(exptime) * time.Second, true
+	default:
+		ttl := time.Unix(exptime, 0).Sub(now())
+
+		return ttl, ttl > 0
+	}
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLen {
+		return false
+	}
+
+	for i := range len(key) {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// now is real `time.Now` function.
+// It is a variable to make it easier to mock in tests.
+var now = time.Now
@@ -0,0 +1,283 @@
+package memcached
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+type conn struct {
+	net.Conn
+
+	r *bufio.Reader
+}
+
+// clock is a fake time for the cache and the server, moved forward by the tests instead of sleeping.
+type clock struct {
+	at atomic.Int64
+}
+
+func (c *clock) now() time.Time {
+	return time.Unix(0, c.at.Load())
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.at.Add(int64(d))
+}
+
+// start serves a fresh cache on a loopback listener and connects to it.
+func start(t *testing.T, capacity int32) *conn {
+	t.Helper()
+
+	return serve(t, sieve.New[string, *Item](capacity))
+}
+
+// startWithClock is start with the cache and the server on a fake clock.
+func startWithClock(t *testing.T, capacity int32) (*conn, *clock) {
+	t.Helper()
+
+	clk := &clock{at: atomic.Int64{}}
+	clk.at.Store(time.Unix(1_700_000_000, 0).UnixNano())
+
+	now = clk.now
+
+	t.Cleanup(func() { now = time.Now })
+
+	return serve(t, sieve.New[string, *Item](capacity).WithClock(clk.now)), clk
+}
+
+// serve serves cache on a loopback listener and connects to it.
+func serve(t *testing.T, cache *sieve.Cache[string, *Item]) *conn {
+	t.Helper()
+
+	s := NewServer(cache)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	go s.Serve(l)
+
+	t.Cleanup(func() { s.Close() })
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+
+	return &conn{Conn: c, r: bufio.NewReader(c)}
+}
+
+// expect sends the request and checks that the reply is exactly expected.
+func (c *conn) expect(t *testing.T, request, expected string) {
+	t.Helper()
+
+	if _, err := io.WriteString(c, request); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	c.SetReadDeadline(time.Now().Add(time.Second))
+
+	got := make([]byte, len(expected))
+	if _, err := io.ReadFull(c.r, got); err != nil {
+		t.Fatalf("%q: expected %q, got %q: %v", request, expected, got, err)
+	}
+
+	if string(got) != expected {
+		t.Errorf("%q: expected %q, got %q", request, expected, got)
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	c := start(t, 10)
+
+	c.expect(t, "get a\r\n", "END\r\n")
+	c.expect(t, "set a 5 0 3\r\none\r\n", "STORED\r\n")
+	c.expect(t, "set b 0 0 0\r\n\r\n", "STORED\r\n")
+	c.expect(t, "get a b c\r\n", "VALUE a 5 3\r\none\r\nVALUE b 0 0\r\n\r\nEND\r\n")
+	c.expect(t, "set a 0 0 3 noreply\r\ntwo\r\nget a\r\n", "VALUE a 0 3\r\ntwo\r\nEND\r\n")
+}
+
+func TestAddReplace(t *testing.T) {
+	c := start(t, 10)
+
+	c.expect(t, "replace a 0 0 1\r\nx\r\n", "NOT_STORED\r\n")
+	c.expect(t, "add a 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "add a 0 0 1\r\ny\r\n", "NOT_STORED\r\n")
+	c.expect(t, "replace a 0 0 1\r\nz\r\n", "STORED\r\n")
+	c.expect(t, "get a\r\n", "VALUE a 0 1\r\nz\r\nEND\r\n")
+}
+
+func TestCAS(t *testing.T) {
+	c := start(t, 10)
+
+	c.expect(t, "cas a 0 0 1 1\r\nx\r\n", "NOT_FOUND\r\n")
+	c.expect(t, "set a 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "set b 0 0 1\r\ny\r\n", "STORED\r\n")
+	c.expect(t, "gets a b\r\n", "VALUE a 0 1 1\r\nx\r\nVALUE b 0 1 2\r\ny\r\nEND\r\n")
+	c.expect(t, "cas a 0 0 1 2\r\nz\r\n", "EXISTS\r\n")
+	c.expect(t, "cas a 0 0 1 1\r\nz\r\n", "STORED\r\n")
+	c.expect(t, "cas a 0 0 1 1\r\nw\r\n", "EXISTS\r\n")
+
+	// touch keeps the token
+	c.expect(t, "touch a 100\r\n", "TOUCHED\r\n")
+	c.expect(t, "gets a\r\n", "VALUE a 0 1 3\r\nz\r\nEND\r\n")
+}
+
+func TestDelete(t *testing.T) {
+	c := start(t, 10)
+
+	c.expect(t, "delete a\r\n", "NOT_FOUND\r\n")
+	c.expect(t, "set a 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "delete a\r\n", "DELETED\r\n")
+	c.expect(t, "get a\r\n", "END\r\n")
+}
+
+func TestExpiration(t *testing.T) {
+	c, clk := startWithClock(t, 10)
+
+	c.expect(t, "set a 0 -1 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "get a\r\n", "END\r\n")
+
+	c.expect(t, "set a 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "touch a -1\r\n", "TOUCHED\r\n")
+	c.expect(t, "get a\r\n", "END\r\n")
+	c.expect(t, "touch a 10\r\n", "NOT_FOUND\r\n")
+
+	c.expect(t, "set a 0 1 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "get a\r\n", "VALUE a 0 1\r\nx\r\nEND\r\n")
+
+	clk.advance(1100 * time.Millisecond)
+
+	c.expect(t, "get a\r\n", "END\r\n")
+}
+
+func TestExpirationMapping(t *testing.T) {
+	now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		exptime int64
+		ttl     time.Duration
+		alive   bool
+	}{
+		{0, 0, true},
+		{-1, 0, false},
+		{60, time.Minute, true},
+		{relativeExpLimit, relativeExpLimit * time.Second, true},
+		{1_700_000_100, 100 * time.Second, true},
+		{1_600_000_000, -100_000_000 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		ttl, alive := expiration(tt.exptime)
+		if ttl != tt.ttl || alive != tt.alive {
+			t.Errorf("%d: expected (%v, %v), got (%v, %v)", tt.exptime, tt.ttl, tt.alive, ttl, alive)
+		}
+	}
+}
+
+func TestFlushAll(t *testing.T) {
+	c, clk := startWithClock(t, 10)
+
+	c.expect(t, "set a 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "flush_all\r\n", "OK\r\n")
+	c.expect(t, "get a\r\n", "END\r\n")
+
+	c.expect(t, "set a 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.expect(t, "flush_all 1 noreply\r\nget a\r\n", "VALUE a 0 1\r\nx\r\nEND\r\n")
+
+	clk.advance(1100 * time.Millisecond)
+
+	c.expect(t, "get a\r\n", "END\r\n")
+
+	// a flush without delay replaces the pending one
+	c.expect(t, "flush_all 10\r\n", "OK\r\n")
+	c.expect(t, "flush_all\r\n", "OK\r\n")
+	c.expect(t, "set a 0 0 1\r\nx\r\n", "STORED\r\n")
+
+	clk.advance(11 * time.Second)
+
+	c.expect(t, "get a\r\n", "VALUE a 0 1\r\nx\r\nEND\r\n")
+}
+
+func TestErrors(t *testing.T) {
+	c := start(t, 10)
+
+	c.expect(t, "bogus\r\n", "ERROR\r\n")
+	c.expect(t, "\r\n", "ERROR\r\n")
+	c.expect(t, "get\r\n", "CLIENT_ERROR bad command line format\r\n")
+	c.expect(t, "set a 0 0\r\n", "CLIENT_ERROR bad command line format\r\n")
+	c.expect(t, "set a x 0 1\r\nx\r\n", "CLIENT_ERROR bad command line format\r\nERROR\r\n")
+	c.expect(t, "set a 0 0 1\r\nxyz\r\n", "CLIENT_ERROR bad data chunk\r\n")
+	c.expect(t, "set "+strings.Repeat("k", maxKeyLen+1)+" 0 0 1\r\nx\r\n", "CLIENT_ERROR bad command line format\r\nERROR\r\n")
+	c.expect(t, "version\r\n", "VERSION "+version+"\r\n")
+}
+
+func TestTooLarge(t *testing.T) {
+	c := start(t, 10)
+
+	big := strings.Repeat("x", maxItemSize+1)
+
+	c.expect(t, "set a 0 0 1048577\r\n"+big+"\r\n", "SERVER_ERROR object too large for cache\r\n")
+	c.expect(t, "get a\r\n", "END\r\n")
+}
+
+func TestStats(t *testing.T) {
+	c := start(t, 2)
+
+	c.expect(t, "set a 0 0 1\r\nx\r\nset b 0 0 1\r\nx\r\nset c 0 0 1\r\nx\r\n", "STORED\r\nSTORED\r\nSTORED\r\n")
+	c.expect(t, "get a c\r\n", "VALUE c 0 1\r\nx\r\nEND\r\n")
+
+	io.WriteString(c, "stats\r\n")
+
+	stats := map[string]string{}
+
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil || line == "END\r\n" {
+			break
+		}
+
+		fields := strings.Fields(line)
+		stats[fields[1]] = fields[2]
+	}
+
+	expected := map[string]string{
+		"cmd_get":     "2",
+		"cmd_set":     "3",
+		"get_hits":    "1",
+		"get_misses":  "1",
+		"curr_items":  "2",
+		"limit_items": "2",
+		"evictions":   "1",
+	}
+
+	for k, v := range expected {
+		if stats[k] != v {
+			t.Errorf("expected STAT %s %s, got %q", k, v, stats[k])
+		}
+	}
+}
+
+func TestQuit(t *testing.T) {
+	c := start(t, 10)
+
+	io.WriteString(c, "quit\r\n")
+
+	c.SetReadDeadline(time.Now().Add(time.Second))
+
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("expected the connection to be closed, got %v", err)
+	}
+}
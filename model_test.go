@@ -0,0 +1,263 @@
+package sieve
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// model is a reference SIEVE kept as simply as possible, to compare the sieve with.
+// The entries go from the tail, at index 0, to the head, so the hand moves towards higher indexes.
+type model struct {
+	entries  []modelEntry
+	hand     int
+	capacity int
+	ttl      time.Duration
+}
+
+type modelEntry struct {
+	key     int
+	value   int
+	visited bool
+	access  time.Time
+}
+
+func (m *model) index(key int) int {
+	for i, e := range m.entries {
+		if e.key == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (m *model) expired(e modelEntry, atNow time.Time) bool {
+	return m.ttl > 0 && atNow.Sub(e.access) > m.ttl
+}
+
+// remove drops the entry at i, the hand keeps pointing to the same entry or moves to the next one.
+func (m *model) remove(i int) {
+	m.entries = append(m.entries[:i], m.entries[i+1:]...)
+
+	if i < m.hand {
+		m.hand--
+	}
+
+	if m.hand >= len(m.entries) {
+		m.hand = 0
+	}
+}
+
+// set returns the evicted key, or -1.
+func (m *model) set(key, value int, atNow time.Time) int {
+	if i := m.index(key); i >= 0 {
+		m.entries[i].visited = true
+		m.entries[i].value = value
+		m.entries[i].access = atNow
+
+		return -1
+	}
+
+	victim := -1
+
+	if len(m.entries) == m.capacity {
+		for m.entries[m.hand].visited && !m.expired(m.entries[m.hand], atNow) {
+			m.entries[m.hand].visited = false
+			m.hand = (m.hand + 1) % len(m.entries)
+		}
+
+		victim = m.entries[m.hand].key
+		m.remove(m.hand)
+	}
+
+	m.entries = append(m.entries, modelEntry{key: key, value: value, visited: false, access: atNow})
+
+	return victim
+}
+
+func (m *model) get(key int, atNow time.Time) (int, bool) {
+	i := m.index(key)
+	if i < 0 {
+		return 0, false
+	}
+
+	if m.expired(m.entries[i], atNow) {
+		m.remove(i)
+
+		return 0, false
+	}
+
+	m.entries[i].visited = true
+	m.entries[i].access = atNow
+
+	return m.entries[i].value, true
+}
+
+func (m *model) delete(key int) bool {
+	i := m.index(key)
+	if i < 0 {
+		return false
+	}
+
+	m.remove(i)
+
+	return true
+}
+
+func (m *model) flush() {
+	m.entries = nil
+	m.hand = 0
+}
+
+// compare fails if the sieve, from tail to head, is not the same as the model.
+func compare(t *testing.T, step int, s *Cache[int, int], m *model) {
+	t.Helper()
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("step %d: %v", step, err)
+	}
+
+	if int(s.Len()) != len(m.entries) {
+		t.Fatalf("step %d: expected len %d, got %d", step, len(m.entries), s.Len())
+	}
+
+	i := 0
+
+	for n := s.tail; n != nil; n = n.prev {
+		e := m.entries[i]
+
+		if n.key != e.key || n.value != e.value || n.visited != e.visited {
+			t.Fatalf("step %d: expected %+v at %d, got key %d value %d visited %v", step, e, i, n.key, n.value, n.visited)
+		}
+
+		if (n == s.hand) != (i == m.hand) {
+			t.Fatalf("step %d: expected the hand at %d, got it on key %d", step, m.hand, s.hand.key)
+		}
+
+		i++
+	}
+}
+
+// run interprets data as operations, two bytes each, and applies them to both the sieve and the model.
+func run(t *testing.T, s *Cache[int, int], m *model, data []byte) {
+	t.Helper()
+
+	sec := 0
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	defer func() { now = time.Now }()
+
+	for step := 0; step+1 < len(data); step += 2 {
+		op, key := data[step], int(data[step+1]%16)
+		atNow := now()
+
+		switch op % 8 {
+		case 0, 1, 2:
+			evicted := m.set(key, step, atNow)
+
+			before := keys(s)
+			s.Set(key, step)
+
+			victim := -1
+
+			after := keys(s)
+
+			for k := range before {
+				if _, ok := after[k]; !ok {
+					victim = k
+				}
+			}
+
+			if victim != evicted {
+				t.Fatalf("step %d: expected %d to be evicted, got %d", step, evicted, victim)
+			}
+		case 3, 4:
+			ev, eok := m.get(key, atNow)
+
+			if v, ok := s.Get(key); v != ev || ok != eok {
+				t.Fatalf("step %d: expected Get(%d) to be %d, %v, got %d, %v", step, key, ev, eok, v, ok)
+			}
+		case 5:
+			// Delete does not look at the deadlines
+			if ok := s.Delete(key); ok != m.delete(key) {
+				t.Fatalf("step %d: expected Delete(%d) to be %v", step, key, !ok)
+			}
+		case 6:
+			sec += key
+		case 7:
+			// flushing is rare, or there would be nothing to compare
+			if key == 0 {
+				m.flush()
+				s.Flush()
+			}
+		}
+
+		compare(t, step, s, m)
+	}
+}
+
+// keys returns the keys of the sieve, without touching it.
+func keys(s *Cache[int, int]) map[int]struct{} {
+	ks := make(map[int]struct{}, len(s.m))
+	for k := range s.m {
+		ks[k] = struct{}{}
+	}
+
+	return ks
+}
+
+// seeds are inputs covering the cases with many special ones: len 1, len 2, all visited, hand on the tail.
+var seeds = [][]byte{
+	{0, 1, 0, 2, 0, 3, 0, 4, 0, 5},
+	{0, 1, 3, 1, 0, 2, 0, 3},
+	{0, 1, 0, 2, 3, 1, 3, 2, 0, 3, 5, 3, 0, 4},
+	{0, 1, 0, 2, 0, 3, 0, 4, 3, 1, 3, 2, 3, 3, 3, 4, 0, 5, 5, 2, 0, 6},
+	{0, 1, 6, 3, 3, 1, 6, 9, 3, 1, 0, 2},
+	{0, 1, 0, 2, 7, 0, 0, 3, 5, 3, 0, 4},
+}
+
+func fuzz(f *testing.F, newCache func() *Cache[int, int], ttl time.Duration) {
+	f.Helper()
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := newCache()
+		m := &model{entries: nil, hand: 0, capacity: int(s.capacity), ttl: ttl}
+
+		run(t, s, m, data)
+	})
+}
+
+func FuzzSieve(f *testing.F) {
+	fuzz(f, func() *Cache[int, int] { return New[int, int](4) }, 0)
+}
+
+func FuzzSieveSingleThread(f *testing.F) {
+	fuzz(f, func() *Cache[int, int] { return NewSingleThread[int, int](4) }, 0)
+}
+
+func FuzzSieveTTL(f *testing.F) {
+	fuzz(f, func() *Cache[int, int] { return New[int, int](4).WithTTL(5 * time.Second) }, 5*time.Second)
+}
+
+func TestModel(t *testing.T) {
+	// the model itself, on the scenario of TestAllAreVisited: the hand starts on the unvisited 1
+	m := &model{entries: nil, hand: 0, capacity: 2, ttl: 0}
+	atNow := time.Now()
+
+	m.set(1, 1, atNow)
+	m.set(2, 2, atNow)
+	m.get(2, atNow)
+
+	if victim := m.set(3, 3, atNow); victim != 1 {
+		t.Errorf("expected 1 to be evicted, got %d", victim)
+	}
+
+	if got := fmt.Sprint(m.entries); got != fmt.Sprint([]modelEntry{{2, 2, true, atNow}, {3, 3, false, atNow}}) {
+		t.Errorf("expected [2 3], got %s", got)
+	}
+}
@@ -0,0 +1,141 @@
+package sieve
+
+import "time"
+
+// Namespace is a view of a sieve over its own keys: the same key in two namespaces, or in a namespace
+// and in the sieve itself, is two entries. The namespaces share the capacity and the hand of the sieve.
+// The refresh set by WithStaleWhileRevalidate only knows the key, so the keys of a namespace
+// are not refreshed and expire at the soft deadline.
+type Namespace[K comparable, V any] struct {
+	cache *Cache[K, V]
+	ns    *namespace[K, V]
+}
+
+// namespace holds the keys of a namespace and its generation, the nodes written with an older one are expired.
+type namespace[K comparable, V any] struct {
+	name string
+	gen  uint64
+	m    map[K]*node[K, V]
+}
+
+// Namespace returns the namespace called name, creating it on the first call.
+func (s *Cache[K, V]) Namespace(name string) *Namespace[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &Namespace[K, V]{cache: s, ns: s.namespace(name)}
+}
+
+// namespace returns the namespace called name, creating it if needed.
+func (s *Cache[K, V]) namespace(name string) *namespace[K, V] {
+	if s.namespaces == nil {
+		s.namespaces = make(map[string]*namespace[K, V])
+	}
+
+	ns, ok := s.namespaces[name]
+	if !ok {
+		ns = &namespace[K, V]{name: name, gen: 0, m: make(map[K]*node[K, V])}
+		s.namespaces[name] = ns
+	}
+
+	return ns
+}
+
+// keyspace returns the map holding the keys of ns, the one of the sieve itself if ns is nil.
+func (s *Cache[K, V]) keyspace(ns *namespace[K, V]) map[K]*node[K, V] {
+	if ns == nil {
+		return s.m
+	}
+
+	return ns.m
+}
+
+// Name returns the name of the namespace.
+func (n *Namespace[K, V]) Name() string {
+	return n.ns.name
+}
+
+// Set inserts a key-value pair in the namespace like Cache.Set.
+func (n *Namespace[K, V]) Set(key K, value V) {
+	n.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL inserts a key-value pair in the namespace like Cache.SetWithTTL.
+func (n *Namespace[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	s := n.cache
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = s.now().Add(ttl)
+	}
+
+	s.setIn(n.ns, key, value, expiresAt)
+}
+
+// Get returns the value of the key in the namespace like Cache.Get.
+func (n *Namespace[K, V]) Get(key K) (V, bool) {
+	s := n.cache
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, res := s.getIn(n.ns, key, s.now())
+
+	return v, res == Hit
+}
+
+// Contains reports whether the key is in the namespace like Cache.Contains.
+func (n *Namespace[K, V]) Contains(key K) bool {
+	s := n.cache
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	node, ok := s.lookupIn(n.ns, key, s.now())
+
+	return ok && !node.absent
+}
+
+// Delete removes the key from the namespace.
+// It returns true if the key was present.
+func (n *Namespace[K, V]) Delete(key K) bool {
+	s := n.cache
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	node, ok := n.ns.m[key]
+	if !ok {
+		return false
+	}
+
+	// a key of an older generation is already gone, the nodes of a namespace always have their extra fields
+	if node.extra.gen != n.ns.gen {
+		s.removeNode(node, Expired)
+
+		s.stats.expirations.Add(1)
+
+		return false
+	}
+
+	s.removeNode(node, Deleted)
+
+	return true
+}
+
+// Flush removes all the keys of the namespace in constant time, by bumping its generation.
+// The keys disappear at once for every read, while their nodes are reclaimed lazily
+// by the eviction hand and by the lookups, like the expired ones.
+func (n *Namespace[K, V]) Flush() {
+	s := n.cache
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n.ns.gen++
+
+	s.logNamespace(n.ns)
+}
@@ -0,0 +1,208 @@
+package sieve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespaceFlush(t *testing.T) {
+	s := New[string, int](10)
+	a := s.Namespace("a")
+	b := s.Namespace("b")
+
+	a.Set("a1", 1)
+	a.Set("a2", 2)
+	b.Set("b1", 3)
+	s.Set("plain", 4)
+
+	if a.Name() != "a" || s.Namespace("a").ns != a.ns {
+		t.Errorf("expected the same namespace for the same name")
+	}
+
+	if v, ok := a.Get("a1"); !ok || v != 1 {
+		t.Errorf("expected 1, got %d", v)
+	}
+
+	// the key is not part of a
+	if _, ok := a.Get("b1"); ok {
+		t.Errorf("expected b1 to be missing from a")
+	}
+
+	a.Flush()
+
+	for _, k := range []string{"a1", "a2"} {
+		if _, ok := a.Get(k); ok {
+			t.Errorf("expected %s to be gone from a", k)
+		}
+	}
+
+	// the lookups reclaimed the stale nodes
+	if s.Len() != 2 {
+		t.Errorf("expected a1 and a2 to be removed, got len %d", s.Len())
+	}
+
+	if v, ok := b.Get("b1"); !ok || v != 3 {
+		t.Errorf("expected b1 to survive, got %d", v)
+	}
+
+	if !s.Contains("plain") {
+		t.Errorf("expected plain to survive")
+	}
+
+	// the new generation works as usual
+	a.Set("a1", 10)
+
+	if v, ok := a.Get("a1"); !ok || v != 10 {
+		t.Errorf("expected 10, got %d", v)
+	}
+}
+
+func TestNamespaceLazyReclaim(t *testing.T) {
+	s := New[int, int](3)
+	a := s.Namespace("a")
+
+	a.Set(1, 1)
+	a.Set(2, 2)
+	s.Set(3, 3)
+
+	// all visited, a plain sieve would evict 1 after a full sweep
+	a.Get(1)
+	a.Get(2)
+	s.Get(3)
+
+	a.Flush()
+
+	// the nodes are still there until the hand reaches them
+	if s.Len() != 3 {
+		t.Errorf("expected the flush to not walk the nodes, got len %d", s.Len())
+	}
+
+	s.Set(4, 4)
+	s.Set(5, 5)
+
+	if !s.Contains(3) || !s.Contains(4) || !s.Contains(5) {
+		t.Errorf("expected the stale keys to be reclaimed first, got %s", s)
+	}
+
+	if st := s.Stats(); st.Expirations != 2 || st.Evictions != 0 {
+		t.Errorf("expected 2 expirations and no evictions, got %+v", st)
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	s := New[int, int](10)
+	a := s.Namespace("a")
+	b := s.Namespace("b")
+
+	a.Set(1, 1)
+	b.Set(1, 2)
+	s.Set(1, 3)
+
+	// the same key is three entries
+	if s.Len() != 3 {
+		t.Errorf("expected 3 keys, got %d", s.Len())
+	}
+
+	for _, tt := range []struct {
+		get      func(int) (int, bool)
+		expected int
+	}{{a.Get, 1}, {b.Get, 2}, {s.Get, 3}} {
+		if v, ok := tt.get(1); !ok || v != tt.expected {
+			t.Errorf("expected %d, got %d", tt.expected, v)
+		}
+	}
+
+	// a write in one namespace does not touch the others
+	b.Set(1, 20)
+
+	if v, _ := a.Get(1); v != 1 {
+		t.Errorf("expected the key of a to be kept, got %d", v)
+	}
+
+	if !a.Delete(1) || a.Contains(1) {
+		t.Errorf("expected 1 to be deleted from a")
+	}
+
+	if !b.Contains(1) || !s.Contains(1) {
+		t.Errorf("expected the delete to only touch a")
+	}
+
+	b.Flush()
+
+	if b.Contains(1) || !s.Contains(1) {
+		t.Errorf("expected the flush to only touch b")
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Flush()
+
+	if s.Len() != 0 || b.Contains(1) {
+		t.Errorf("expected the flush of the sieve to empty the namespaces")
+	}
+
+	b.Set(2, 2)
+
+	if v, ok := b.Get(2); !ok || v != 2 {
+		t.Errorf("expected the namespace to work after a flush, got %d", v)
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamespaceTTLAndDelete(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New[int, int](4).WithClock(func() time.Time { return at })
+	a := s.Namespace("a")
+
+	a.SetWithTTL(1, 1, time.Second)
+	a.Set(2, 2)
+
+	at = at.Add(2 * time.Second)
+
+	if a.Contains(1) || !a.Contains(2) {
+		t.Errorf("expected only 1 to expire")
+	}
+
+	if a.Delete(3) {
+		t.Errorf("expected a missing key to not be deleted")
+	}
+
+	a.Flush()
+
+	// the node of the older generation is reclaimed, but it was not there to delete
+	if a.Delete(2) || s.Len() != 0 {
+		t.Errorf("expected 2 to be gone after the flush, got len %d", s.Len())
+	}
+
+	if st := s.Stats(); st.Expirations != 2 {
+		t.Errorf("expected 2 expirations, got %+v", st)
+	}
+}
+
+func TestNamespaceNotRefreshed(t *testing.T) {
+	r := newRefresher()
+	close(r.release)
+
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New[int, string](4).WithClock(func() time.Time { return at }).
+		WithStaleWhileRevalidate(time.Second, time.Minute, r.refresh)
+	a := s.Namespace("a")
+
+	a.Set(1, "one")
+
+	at = at.Add(2 * time.Second)
+
+	// the refresh would load the key of the sieve, so the stale key of the namespace is gone instead
+	if _, ok := a.Get(1); ok {
+		t.Errorf("expected the stale key of the namespace to expire")
+	}
+
+	if r.calls.Load() != 0 {
+		t.Errorf("expected no refresh, got %d", r.calls.Load())
+	}
+}
@@ -0,0 +1,70 @@
+package sieve
+
+import "time"
+
+// Result tells apart the outcomes of Lookup.
+type Result int
+
+const (
+	// Miss means the sieve knows nothing about the key.
+	Miss Result = iota
+	// Hit means the key has a value.
+	Hit
+	// Absent means the key is known not to exist, it was stored by SetNotFound.
+	Absent
+)
+
+func (r Result) String() string {
+	switch r {
+	case Miss:
+		return "miss"
+	case Hit:
+		return "hit"
+	case Absent:
+		return "absent"
+	default:
+		return "unknown"
+	}
+}
+
+// WithNegativeTTL is a builder function used to set the default TTL of the entries stored by SetNotFound.
+// It also makes LoadingCache and BatchLoader remember the keys their backend does not have.
+func (s *Cache[K, V]) WithNegativeTTL(ttl time.Duration) *Cache[K, V] {
+	s.negativeTTL = ttl
+
+	return s
+}
+
+// SetNotFound stores a negative entry for the key, meaning that it is known not to exist.
+// The entry takes room and is evicted like any other one, and it expires after the TTL set with WithNegativeTTL.
+func (s *Cache[K, V]) SetNotFound(key K) {
+	s.SetNotFoundWithTTL(key, s.negativeTTL)
+}
+
+// SetNotFoundWithTTL is like SetNotFound with its own TTL.
+// A ttl less than or equal to zero means the entry has no deadline of its own.
+func (s *Cache[K, V]) SetNotFoundWithTTL(key K, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = s.now().Add(ttl)
+	}
+
+	var zeroValue V
+
+	if n := s.set(key, zeroValue, expiresAt); n != nil {
+		n.absent = true
+
+		s.logSet(n)
+	}
+}
+
+// Lookup is like Get, but it tells apart a key the sieve knows nothing about from a key stored by SetNotFound.
+func (s *Cache[K, V]) Lookup(key K) (V, Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(key, s.now())
+}
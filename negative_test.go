@@ -0,0 +1,109 @@
+package sieve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetNotFound(t *testing.T) {
+	s := New[int, string](2).WithNegativeTTL(2 * time.Second)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.SetNotFound(7)
+
+	if v, res := s.Lookup(7); res != Absent || v != "" {
+		t.Errorf("expected key 7 to be absent, got %s with '%s'", res, v)
+	}
+
+	if _, res := s.Lookup(8); res != Miss {
+		t.Errorf("expected key 8 to be a miss, got %s", res)
+	}
+
+	if _, ok := s.Get(7); ok {
+		t.Errorf("expected Get to not find key 7")
+	}
+
+	if s.Contains(7) {
+		t.Errorf("expected Contains to not find key 7")
+	}
+
+	if s.Len() != 1 {
+		t.Errorf("expected the negative entry to take room, got len %d", s.Len())
+	}
+
+	stats := s.Stats()
+	if stats.NegativeHits != 2 || stats.Misses != 1 || stats.Hits != 0 {
+		t.Errorf("expected 2 negative hits and 1 miss, got %+v", stats)
+	}
+
+	// past the negative TTL the sieve knows nothing about it
+	sec = 4
+
+	if _, res := s.Lookup(7); res != Miss {
+		t.Errorf("expected key 7 to be a miss, got %s", res)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	for res, name := range map[Result]string{Miss: "miss", Hit: "hit", Absent: "absent", Result(9): "unknown"} {
+		if res.String() != name {
+			t.Errorf("expected %s, got %s", name, res)
+		}
+	}
+}
+
+func TestSetNotFoundVisited(t *testing.T) {
+	s := New[int, string](2)
+
+	s.SetNotFound(7)
+	s.Set(8, "eight")
+	s.Lookup(7)
+
+	// 7 is visited, so 8 is evicted
+	s.Set(9, "nine")
+
+	if _, res := s.Lookup(7); res != Absent {
+		t.Errorf("expected key 7 to survive, got %s", res)
+	}
+
+	if s.Contains(8) {
+		t.Errorf("expected key 8 to be evicted")
+	}
+
+	// a value replaces the negative entry
+	s.Set(7, "seven")
+
+	if v, res := s.Lookup(7); res != Hit || v != "seven" {
+		t.Errorf("expected 'seven', got %s with '%s'", res, v)
+	}
+}
+
+func TestSetNotFoundWithTTL(t *testing.T) {
+	s := New[int, string](2).WithNegativeTTL(time.Hour)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.SetNotFoundWithTTL(7, time.Second)
+
+	sec = 3
+
+	if _, res := s.Lookup(7); res != Miss {
+		t.Errorf("expected key 7 to be a miss, got %s", res)
+	}
+}
+
+func TestGetManyAbsent(t *testing.T) {
+	s := New[int, string](4)
+
+	s.Set(1, "one")
+	s.SetNotFound(2)
+
+	values, missing := s.GetMany([]int{1, 2, 3})
+
+	if len(values) != 1 || len(missing) != 1 || missing[0] != 3 {
+		t.Errorf("expected only 1 found and only 3 missing, got %v and %v", values, missing)
+	}
+}
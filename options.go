@@ -0,0 +1,261 @@
+package sieve
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidConfig is wrapped by every ConfigError, to check for any of them with errors.Is.
+var ErrInvalidConfig = errors.New("sieve: invalid configuration")
+
+// ConfigError is returned by NewWithOptions when an option has an invalid value.
+type ConfigError struct {
+	// Option is the name of the invalid option, like "size".
+	Option string
+	// Reason tells what the value should be.
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return "sieve: " + e.Option + " " + e.Reason
+}
+
+// Unwrap returns ErrInvalidConfig.
+func (e *ConfigError) Unwrap() error {
+	return ErrInvalidConfig
+}
+
+// Option configures a sieve built by NewWithOptions.
+type Option func(*config)
+
+type config struct {
+	size         int32
+	ttl          time.Duration
+	negativeTTL  time.Duration
+	maxPinned    int32
+	singleThread bool
+	// pinLimitSet tells if WithMaxPinned was used, otherwise the limit defaults to the size minus one
+	pinLimitSet bool
+
+	softTTL      time.Duration
+	hardTTL      time.Duration
+	refreshAhead time.Duration
+	// refresh is a func(key K) (V, error), checked against the types of the sieve by NewWithOptions
+	refresh any
+
+	maxSweep int
+	logger   *slog.Logger
+}
+
+// WithSize sets the maximum number of elements that the sieve can hold, it is required.
+func WithSize(size int32) Option {
+	return func(c *config) {
+		c.size = size
+	}
+}
+
+// WithTTL sets the expiration of the keys, like Cache.WithTTL.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithNegativeTTL sets the default TTL of the entries stored by SetNotFound, like Cache.WithNegativeTTL.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithMaxPinned limits how many keys can be pinned at the same time, like Cache.WithMaxPinned.
+func WithMaxPinned(limit int32) Option {
+	return func(c *config) {
+		c.maxPinned = limit
+		c.pinLimitSet = true
+	}
+}
+
+// WithStaleWhileRevalidate serves stale values while they are refreshed, like Cache.WithStaleWhileRevalidate.
+// The key and value types of refresh must be the ones of the sieve.
+func WithStaleWhileRevalidate[K comparable, V any](soft, hard time.Duration, refresh func(key K) (V, error)) Option {
+	return func(c *config) {
+		c.softTTL = soft
+		c.hardTTL = hard
+		c.refresh = nil
+
+		if refresh != nil {
+			c.refresh = refresh
+		}
+	}
+}
+
+// WithRefreshAhead refreshes hot keys before they become stale, like Cache.WithRefreshAhead.
+// It needs WithStaleWhileRevalidate.
+func WithRefreshAhead(window time.Duration) Option {
+	return func(c *config) {
+		c.refreshAhead = window
+	}
+}
+
+// WithMaxSweep bounds the work of one eviction, like Cache.WithMaxSweep. Zero means no bound.
+func WithMaxSweep(steps int) Option {
+	return func(c *config) {
+		c.maxSweep = steps
+	}
+}
+
+// WithLogger logs the rare events of the sieve, like Cache.WithLogger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithSingleThread makes the sieve safe for single-threaded use only, like NewSingleThread.
+func WithSingleThread() Option {
+	return func(c *config) {
+		c.singleThread = true
+	}
+}
+
+// validate returns a ConfigError for the first invalid option.
+func (c *config) validate() error {
+	switch {
+	case c.size <= 0:
+		return &ConfigError{Option: "size", Reason: "must be greater than zero"}
+	case c.ttl < 0:
+		return &ConfigError{Option: "ttl", Reason: "must not be negative"}
+	case c.negativeTTL < 0:
+		return &ConfigError{Option: "negative ttl", Reason: "must not be negative"}
+	case c.maxPinned < 0:
+		return &ConfigError{Option: "max pinned", Reason: "must not be negative"}
+	case c.maxPinned >= c.size:
+		return maxPinnedError()
+	case c.softTTL != 0 || c.hardTTL != 0:
+		if c.softTTL <= 0 || c.softTTL >= c.hardTTL {
+			return softTTLError()
+		}
+
+		if c.refresh == nil {
+			return &ConfigError{Option: "refresh", Reason: "must not be nil"}
+		}
+
+		// refreshes run on their own goroutine
+		if c.singleThread {
+			return &ConfigError{Option: "stale while revalidate", Reason: "needs a thread-safe sieve"}
+		}
+	}
+
+	switch {
+	case c.refreshAhead < 0:
+		return &ConfigError{Option: "refresh ahead", Reason: "must not be negative"}
+	case c.refreshAhead > 0 && c.softTTL == 0:
+		return &ConfigError{Option: "refresh ahead", Reason: "needs stale while revalidate"}
+	case c.maxSweep < 0:
+		return &ConfigError{Option: "max sweep", Reason: "must not be negative"}
+	}
+
+	return nil
+}
+
+func maxPinnedError() *ConfigError {
+	return &ConfigError{Option: "max pinned", Reason: "must be lower than the size"}
+}
+
+func softTTLError() *ConfigError {
+	return &ConfigError{Option: "soft ttl", Reason: "must be greater than zero and shorter than the hard ttl"}
+}
+
+// NewWithOptions returns a new sieve configured by opts.
+// Unlike New, it returns a ConfigError instead of panicking when an option is invalid.
+func NewWithOptions[K comparable, V any](opts ...Option) (*Cache[K, V], error) {
+	c := config{
+		size:         0,
+		ttl:          0,
+		negativeTTL:  0,
+		maxPinned:    0,
+		singleThread: false,
+		pinLimitSet:  false,
+
+		softTTL:      0,
+		hardTTL:      0,
+		refreshAhead: 0,
+		refresh:      nil,
+
+		maxSweep: 0,
+		logger:   nil,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	// the default depends on the size
+	if !c.pinLimitSet {
+		c.maxPinned = max(c.size-1, 0)
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	var refresh func(key K) (V, error)
+
+	if c.refresh != nil {
+		r, ok := c.refresh.(func(key K) (V, error))
+		if !ok {
+			return nil, &ConfigError{Option: "refresh", Reason: "must take the key and return the value of the sieve"}
+		}
+
+		refresh = r
+	}
+
+	var logger *eventLogger
+	if c.logger != nil {
+		logger = newEventLogger(c.logger)
+	}
+
+	var mu sync.Locker = &sync.Mutex{}
+	if c.singleThread {
+		mu = noopMutex{}
+	}
+
+	return &Cache[K, V]{
+		head:     nil,
+		tail:     nil,
+		hand:     nil,
+		m:        make(map[K]*node[K, V]),
+		capacity: c.size,
+		len:      atomic.Int32{},
+		ttl:      c.ttl,
+
+		softTTL:      c.softTTL,
+		hardTTL:      c.hardTTL,
+		refreshAhead: c.refreshAhead,
+		refresh:      refresh,
+
+		negativeTTL: c.negativeTTL,
+
+		tags:     nil,
+		prefixes: nil,
+
+		namespaces: nil,
+
+		pinned:    atomic.Int32{},
+		maxPinned: c.maxPinned,
+
+		logger:   logger,
+		maxSweep: c.maxSweep,
+
+		onEvict: nil,
+		journal: nil,
+		clock:   nil,
+
+		stats: newStats(),
+		mu:    mu,
+	}, nil
+}
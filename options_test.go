@@ -0,0 +1,127 @@
+package sieve_test
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	s, err := sieve.NewWithOptions[int, string](
+		sieve.WithSize(2),
+		sieve.WithTTL(time.Minute),
+		sieve.WithNegativeTTL(time.Second),
+		sieve.WithMaxPinned(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Set(1, one)
+	s.Set(2, "two")
+
+	if err := s.Pin(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Pin(2); !errors.Is(err, sieve.ErrPinLimit) {
+		t.Errorf("expected ErrPinLimit, got %v", err)
+	}
+
+	if st := s.Stats(); st.Capacity != 2 || st.Len != 2 {
+		t.Errorf("expected capacity 2 and len 2, got %+v", st)
+	}
+
+	if _, err := sieve.NewWithOptions[int, string](sieve.WithSize(1), sieve.WithSingleThread()); err != nil {
+		t.Errorf("expected a sieve of size 1, got %v", err)
+	}
+}
+
+func refresh(key int) (string, error) {
+	return fmt.Sprint("refreshed ", key), nil
+}
+
+func TestNewWithOptionsStale(t *testing.T) {
+	s, err := sieve.NewWithOptions[int, string](
+		sieve.WithSize(2),
+		sieve.WithStaleWhileRevalidate(time.Second, time.Minute, refresh),
+		sieve.WithRefreshAhead(100*time.Millisecond),
+		sieve.WithMaxSweep(1),
+		sieve.WithLogger(slog.New(slog.DiscardHandler)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Now()
+	s.WithClock(func() time.Time { return at })
+
+	s.Set(1, one)
+
+	at = at.Add(2 * time.Second)
+
+	// stale, refreshed in the background
+	if v, ok := s.Get(1); !ok || v != one {
+		t.Errorf("expected the stale value, got '%s' %v", v, ok)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for v, _ := s.Peek(1); v != "refreshed 1" && time.Now().Before(deadline); v, _ = s.Peek(1) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if v, _ := s.Peek(1); v != "refreshed 1" {
+		t.Errorf("expected the refreshed value, got '%s'", v)
+	}
+}
+
+func TestNewWithOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []sieve.Option
+		option string
+	}{
+		{"missing size", nil, "size"},
+		{"negative size", []sieve.Option{sieve.WithSize(-1)}, "size"},
+		{"negative ttl", []sieve.Option{sieve.WithSize(2), sieve.WithTTL(-time.Second)}, "ttl"},
+		{"negative negative ttl", []sieve.Option{sieve.WithSize(2), sieve.WithNegativeTTL(-time.Second)}, "negative ttl"},
+		{"too many pinned", []sieve.Option{sieve.WithSize(2), sieve.WithMaxPinned(2)}, "max pinned"},
+		{"negative pinned", []sieve.Option{sieve.WithSize(2), sieve.WithMaxPinned(-3)}, "max pinned"},
+		{"minus one pinned", []sieve.Option{sieve.WithSize(2), sieve.WithMaxPinned(-1)}, "max pinned"},
+		{"soft after hard", []sieve.Option{sieve.WithSize(2), sieve.WithStaleWhileRevalidate(time.Minute, time.Second, refresh)}, "soft ttl"},
+		{"zero soft", []sieve.Option{sieve.WithSize(2), sieve.WithStaleWhileRevalidate(0, time.Second, refresh)}, "soft ttl"},
+		{"nil refresh", []sieve.Option{sieve.WithSize(2), sieve.WithStaleWhileRevalidate[int, string](time.Second, time.Minute, nil)}, "refresh"},
+		{"refresh of other types", []sieve.Option{sieve.WithSize(2), sieve.WithStaleWhileRevalidate(time.Second, time.Minute, func(string) (int, error) { return 0, nil })}, "refresh"},
+		{"stale single thread", []sieve.Option{sieve.WithSize(2), sieve.WithSingleThread(), sieve.WithStaleWhileRevalidate(time.Second, time.Minute, refresh)}, "stale while revalidate"},
+		{"refresh ahead alone", []sieve.Option{sieve.WithSize(2), sieve.WithRefreshAhead(time.Second)}, "refresh ahead"},
+		{"negative refresh ahead", []sieve.Option{sieve.WithSize(2), sieve.WithRefreshAhead(-time.Second)}, "refresh ahead"},
+		{"negative max sweep", []sieve.Option{sieve.WithSize(2), sieve.WithMaxSweep(-1)}, "max sweep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := sieve.NewWithOptions[int, string](tt.opts...)
+			if s != nil {
+				t.Errorf("expected no sieve, got one")
+			}
+
+			var cfgErr *sieve.ConfigError
+			if !errors.As(err, &cfgErr) || cfgErr.Option != tt.option {
+				t.Fatalf("expected a ConfigError for %s, got %v", tt.option, err)
+			}
+
+			if !errors.Is(err, sieve.ErrInvalidConfig) {
+				t.Errorf("expected the error to wrap ErrInvalidConfig")
+			}
+		})
+	}
+
+	_, err := sieve.NewWithOptions[int, string]()
+	if err.Error() != panicError {
+		t.Errorf("expected the same message New panics with, got '%v'", err)
+	}
+}
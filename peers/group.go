@@ -0,0 +1,314 @@
+package peers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// BasePath is the path the peers serve the keys under, the key follows it path-escaped.
+const BasePath = "/_sieve/"
+
+const (
+	// DefaultTimeout is the timeout of the default client for the requests to the other peers.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxValueSize is the largest value read from another peer by default.
+	DefaultMaxValueSize = 64 << 20
+)
+
+// Getter loads the value of a key this peer owns, it returns sieve.ErrNotFound if the key does not exist.
+type Getter func(ctx context.Context, key string) ([]byte, error)
+
+// Stats are the counters of a group.
+type Stats struct {
+	Gets uint64
+	// MainHits counts the hits in the cache of the owned keys, HotHits in the hot cache.
+	MainHits uint64
+	HotHits  uint64
+	// PeerLoads counts the values fetched from their owner, PeerErrors the fetches that failed.
+	PeerLoads  uint64
+	PeerErrors uint64
+	// LocalLoads counts the calls to the Getter.
+	LocalLoads uint64
+	// ServerRequests counts the requests of the other peers.
+	ServerRequests uint64
+}
+
+type stats struct {
+	gets, mainHits, hotHits, peerLoads, peerErrors, localLoads, serverRequests atomic.Uint64
+}
+
+// call is a load in flight, the concurrent Gets of the same key wait for it.
+type call struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+}
+
+// flight runs a load once for the concurrent callers with the same key.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func newFlight() *flight {
+	return &flight{mu: sync.Mutex{}, calls: make(map[string]*call)}
+}
+
+// Group is the cache of one peer: the keys it owns are loaded with the Getter and kept in main,
+// the other ones are fetched from their owner and, for a fraction of them, kept in the hot cache.
+type Group struct {
+	self   string
+	ring   *Ring
+	getter Getter
+
+	main *sieve.Cache[string, []byte]
+
+	hot         *sieve.Cache[string, []byte]
+	hotFraction float64
+
+	client       *http.Client
+	maxValueSize int64
+
+	// fetching is for the Gets and serving for the requests of the other peers: if a serving call
+	// joined a fetch of the same key, two peers fetching from each other would wait on each other.
+	fetching *flight
+	serving  *flight
+
+	stats stats
+}
+
+// New returns the group of the peer self, its base URL as the others reach it and as it is on ring.
+func New(self string, ring *Ring, main *sieve.Cache[string, []byte], getter Getter) *Group {
+	return &Group{
+		self:         self,
+		ring:         ring,
+		getter:       getter,
+		main:         main,
+		hot:          nil,
+		hotFraction:  0,
+		client:       &http.Client{Timeout: DefaultTimeout},
+		maxValueSize: DefaultMaxValueSize,
+		fetching:     newFlight(),
+		serving:      newFlight(),
+		stats:        stats{},
+	}
+}
+
+// WithHotCache is a builder function used to keep a fraction of the values fetched from other peers in hot,
+// like groupcache with a tenth: the popular keys end up there after a few fetches, the others rarely.
+func (g *Group) WithHotCache(hot *sieve.Cache[string, []byte], fraction float64) *Group {
+	g.hot = hot
+	g.hotFraction = fraction
+
+	return g
+}
+
+// WithClient is a builder function used to replace the default client for the requests to the other peers,
+// which times out after DefaultTimeout.
+func (g *Group) WithClient(client *http.Client) *Group {
+	g.client = client
+
+	return g
+}
+
+// WithMaxValueSize is a builder function used to change the largest value read from another peer,
+// DefaultMaxValueSize by default. A larger one is a peer error, and the key is loaded locally.
+func (g *Group) WithMaxValueSize(size int64) *Group {
+	g.maxValueSize = size
+
+	return g
+}
+
+// Get returns the value of key from the caches, or from its owner, loading it only once across the fleet.
+// If the owner cannot be reached the key is loaded locally, without caching it.
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	g.stats.gets.Add(1)
+
+	if v, ok := g.main.Get(key); ok {
+		g.stats.mainHits.Add(1)
+
+		return v, nil
+	}
+
+	if g.hot != nil {
+		if v, ok := g.hot.Get(key); ok {
+			g.stats.hotHits.Add(1)
+
+			return v, nil
+		}
+	}
+
+	return g.fetching.do(key, func() ([]byte, error) { return g.fetch(ctx, key) })
+}
+
+// do runs fn once for concurrent calls with the same key, they all get its result.
+func (f *flight) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	f.mu.Lock()
+
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		c.wg.Wait()
+
+		return c.value, c.err
+	}
+
+	c := &call{wg: sync.WaitGroup{}, value: nil, err: nil}
+	c.wg.Add(1)
+	f.calls[key] = c
+
+	f.mu.Unlock()
+
+	c.value, c.err = fn()
+	c.wg.Done()
+
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+
+	return c.value, c.err
+}
+
+func (g *Group) fetch(ctx context.Context, key string) ([]byte, error) {
+	if owner := g.ring.Get(key); owner != "" && owner != g.self {
+		v, err := g.fetchFromPeer(ctx, owner, key)
+		if err == nil {
+			g.stats.peerLoads.Add(1)
+
+			if g.hot != nil && rand.Float64() < g.hotFraction {
+				g.hot.Set(key, v)
+			}
+
+			return v, nil
+		}
+
+		// a key that does not exist does not exist here either
+		if errors.Is(err, sieve.ErrNotFound) {
+			return nil, err
+		}
+
+		g.stats.peerErrors.Add(1)
+
+		return g.loadLocally(ctx, key)
+	}
+
+	return g.loadOwned(ctx, key)
+}
+
+func (g *Group) loadLocally(ctx context.Context, key string) ([]byte, error) {
+	g.stats.localLoads.Add(1)
+
+	return g.getter(ctx, key)
+}
+
+func (g *Group) fetchFromPeer(ctx context.Context, peer, key string) ([]byte, error) {
+	u := strings.TrimSuffix(peer, "/") + BasePath + url.PathEscape(key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		v, err := io.ReadAll(io.LimitReader(resp.Body, g.maxValueSize+1))
+		if err == nil && int64(len(v)) > g.maxValueSize {
+			return nil, fmt.Errorf("peers: %s answered a value over %d bytes", peer, g.maxValueSize)
+		}
+
+		return v, err
+	case http.StatusNotFound:
+		return nil, sieve.ErrNotFound
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return nil, fmt.Errorf("peers: %s answered %s: %s", peer, resp.Status, strings.TrimSpace(string(msg)))
+	}
+}
+
+// ServeHTTP answers the requests of the other peers. The key is served from main or loaded with the Getter,
+// even if this peer does not own it on its ring: the rings of the peers can disagree for a while, and forwarding
+// again could loop. The load joins only the other requests for the key, never a fetch of this peer.
+func (g *Group) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.stats.serverRequests.Add(1)
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	escaped, ok := strings.CutPrefix(r.URL.EscapedPath(), BasePath)
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	key, err := url.PathUnescape(escaped)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	v, ok := g.main.Get(key)
+	if ok {
+		g.stats.mainHits.Add(1)
+	} else {
+		v, err = g.serving.do(key, func() ([]byte, error) { return g.loadOwned(r.Context(), key) })
+	}
+
+	switch {
+	case errors.Is(err, sieve.ErrNotFound):
+		http.NotFound(w, r)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(v)
+	}
+}
+
+// loadOwned loads key with the Getter into main.
+func (g *Group) loadOwned(ctx context.Context, key string) ([]byte, error) {
+	v, err := g.loadLocally(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	g.main.Set(key, v)
+
+	return v, nil
+}
+
+// Stats returns the counters of the group.
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:           g.stats.gets.Load(),
+		MainHits:       g.stats.mainHits.Load(),
+		HotHits:        g.stats.hotHits.Load(),
+		PeerLoads:      g.stats.peerLoads.Load(),
+		PeerErrors:     g.stats.peerErrors.Load(),
+		LocalLoads:     g.stats.localLoads.Load(),
+		ServerRequests: g.stats.serverRequests.Load(),
+	}
+}
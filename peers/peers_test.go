@@ -0,0 +1,312 @@
+package peers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(0)
+
+	if owner := r.Get("a"); owner != "" {
+		t.Errorf("expected no owner on an empty ring, got %q", owner)
+	}
+
+	r.Add("p1", "p2", "p3")
+
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+
+	for i := range 3000 {
+		key := fmt.Sprint("key", i)
+		owners[key] = r.Get(key)
+		counts[owners[key]]++
+	}
+
+	// roughly a third each
+	for p, n := range counts {
+		if n < 500 || n > 1500 {
+			t.Errorf("expected about 1000 keys on %s, got %d", p, n)
+		}
+	}
+
+	r.Remove("p2")
+
+	for key, owner := range owners {
+		got := r.Get(key)
+
+		if owner != "p2" && got != owner {
+			t.Fatalf("expected %s to stay on %s, got %s", key, owner, got)
+		}
+
+		if got == "p2" {
+			t.Fatalf("expected %s to leave the removed peer", key)
+		}
+	}
+
+	if peers := r.Peers(); fmt.Sprint(peers) != "[p1 p3]" {
+		t.Errorf("expected [p1 p3], got %v", peers)
+	}
+}
+
+// peer is a group served on a loopback listener, with its own counter of loads.
+type peer struct {
+	url   string
+	group *Group
+	loads atomic.Int64
+	srv   *http.Server
+}
+
+// startPeer serves a group on a loopback listener, its loads take delay.
+// The peer is not on ring, so that the rings of the peers can differ.
+func startPeer(t *testing.T, ring *Ring, hotFraction float64, delay time.Duration) *peer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &peer{url: "http://" + l.Addr().String(), group: nil, loads: atomic.Int64{}, srv: nil}
+
+	p.group = New(p.url, ring, sieve.New[string, []byte](100), func(_ context.Context, key string) ([]byte, error) {
+		p.loads.Add(1)
+
+		time.Sleep(delay)
+
+		if key == "missing" {
+			return nil, sieve.ErrNotFound
+		}
+
+		return []byte("value of " + key), nil
+	}).WithHotCache(sieve.New[string, []byte](10), hotFraction)
+
+	p.srv = &http.Server{Handler: p.group} //nolint: gosec // only in tests
+
+	go p.srv.Serve(l)
+
+	t.Cleanup(func() { p.srv.Close() })
+
+	return p
+}
+
+// fleet starts n peers sharing the same ring.
+func fleet(t *testing.T, n int, hotFraction float64) []*peer {
+	t.Helper()
+
+	ring := NewRing(0)
+
+	var peers []*peer
+
+	for range n {
+		p := startPeer(t, ring, hotFraction, 0)
+
+		ring.Add(p.url)
+
+		peers = append(peers, p)
+	}
+
+	return peers
+}
+
+func (p *peer) get(t *testing.T, key string) string {
+	t.Helper()
+
+	v, err := p.group.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("get %s from %s: %v", key, p.url, err)
+	}
+
+	return string(v)
+}
+
+func TestLoadedOnceByTheOwner(t *testing.T) {
+	peers := fleet(t, 3, 0)
+
+	for i := range 30 {
+		key := fmt.Sprint("key", i)
+
+		for _, p := range peers {
+			if got := p.get(t, key); got != "value of "+key {
+				t.Fatalf("expected the value of %s, got %q", key, got)
+			}
+		}
+	}
+
+	total := int64(0)
+
+	for _, p := range peers {
+		total += p.loads.Load()
+
+		// every key went through its owner
+		if st := p.group.Stats(); st.PeerErrors != 0 {
+			t.Errorf("expected no peer errors on %s, got %+v", p.url, st)
+		}
+	}
+
+	if total != 30 {
+		t.Errorf("expected each key loaded once in the fleet, got %d loads", total)
+	}
+}
+
+func TestHotCache(t *testing.T) {
+	peers := fleet(t, 2, 1)
+
+	owner := peers[0].group.ring.Get("hot")
+
+	other := peers[0]
+	if other.url == owner {
+		other = peers[1]
+	}
+
+	other.get(t, "hot")
+	other.get(t, "hot")
+
+	st := other.group.Stats()
+	if st.PeerLoads != 1 || st.HotHits != 1 {
+		t.Errorf("expected the second get from the hot cache, got %+v", st)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	peers := fleet(t, 3, 0)
+
+	for _, p := range peers {
+		if _, err := p.group.Get(context.Background(), "missing"); !errors.Is(err, sieve.ErrNotFound) {
+			t.Errorf("expected ErrNotFound from %s, got %v", p.url, err)
+		}
+	}
+}
+
+func TestOwnerDown(t *testing.T) {
+	peers := fleet(t, 2, 0)
+
+	key := "key"
+	owner, other := peers[0], peers[1]
+
+	if owner.group.ring.Get(key) != owner.url {
+		owner, other = other, owner
+	}
+
+	owner.srv.Close()
+
+	if got := other.get(t, key); got != "value of key" {
+		t.Errorf("expected the value loaded locally, got %q", got)
+	}
+
+	if st := other.group.Stats(); st.PeerErrors != 1 || st.LocalLoads != 1 {
+		t.Errorf("expected a peer error and a local load, got %+v", st)
+	}
+
+	// not owned, so not kept: the owner may come back
+	if other.group.main.Contains(key) {
+		t.Errorf("expected the key to not be cached by a peer that does not own it")
+	}
+}
+
+func TestConcurrentGetsLoadOnce(t *testing.T) {
+	peers := fleet(t, 3, 0)
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		for _, p := range peers {
+			wg.Go(func() {
+				if _, err := p.group.Get(context.Background(), "shared"); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+
+	wg.Wait()
+
+	total := int64(0)
+	for _, p := range peers {
+		total += p.loads.Load()
+	}
+
+	if total != 1 {
+		t.Errorf("expected one load for concurrent gets, got %d", total)
+	}
+}
+
+func TestEscapedKeys(t *testing.T) {
+	peers := fleet(t, 3, 0)
+
+	for _, key := range []string{"a/b", "with space", "100%", "ünïcode?x=1#y"} {
+		for _, p := range peers {
+			if got := p.get(t, key); got != "value of "+key {
+				t.Errorf("expected the value of %q, got %q", key, got)
+			}
+		}
+	}
+}
+
+func TestDisagreeingRings(t *testing.T) {
+	ringA, ringB := NewRing(0), NewRing(0)
+
+	a := startPeer(t, ringA, 0, 10*time.Millisecond)
+	b := startPeer(t, ringB, 0, 10*time.Millisecond)
+
+	// each one thinks the other owns every key
+	ringA.Add(b.url)
+	ringB.Add(a.url)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	for i := range 10 {
+		for _, p := range []*peer{a, b} {
+			for range 5 {
+				wg.Go(func() {
+					if _, err := p.group.Get(ctx, fmt.Sprint("key", i)); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		}
+	}
+
+	wg.Wait()
+
+	// a fetch waiting on a fetch of the other peer would time out and count as a peer error
+	for _, p := range []*peer{a, b} {
+		if st := p.group.Stats(); st.PeerErrors != 0 {
+			t.Errorf("expected no peer errors on %s, got %+v", p.url, st)
+		}
+	}
+}
+
+func TestMaxValueSize(t *testing.T) {
+	peers := fleet(t, 2, 0)
+
+	key := "key"
+	owner, other := peers[0], peers[1]
+
+	if owner.group.ring.Get(key) != owner.url {
+		owner, other = other, owner
+	}
+
+	other.group.WithMaxValueSize(int64(len("value of key")) - 1)
+
+	// too large from the owner, so loaded locally
+	if got := other.get(t, key); got != "value of key" {
+		t.Errorf("expected the value loaded locally, got %q", got)
+	}
+
+	if st := other.group.Stats(); st.PeerErrors != 1 || st.LocalLoads != 1 {
+		t.Errorf("expected a peer error and a local load, got %+v", st)
+	}
+}
@@ -0,0 +1,134 @@
+// Package peers spreads a cache over a fleet: each key is owned by one peer, chosen on a consistent hash ring,
+// which loads it and keeps it in its sieve, and the other peers fetch it from the owner over HTTP.
+// The keys fetched from other peers can be kept in a small hot cache, so that the popular ones do not
+// make every request go through the owner.
+package peers
+
+import (
+	"hash/crc32"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// DefaultReplicas is the number of points of each peer on the ring.
+const DefaultReplicas = 50
+
+// Ring is a consistent hash ring: a key goes to the first point after its hash,
+// so adding or removing a peer moves only the keys of that peer.
+type Ring struct {
+	replicas int
+
+	mu     sync.RWMutex
+	hashes []uint32
+	owners map[uint32]string
+	peers  map[string]struct{}
+}
+
+// NewRing returns a ring placing each peer at replicas points, DefaultReplicas if replicas is not positive.
+func NewRing(replicas int, peers ...string) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	r := &Ring{
+		replicas: replicas,
+		mu:       sync.RWMutex{},
+		hashes:   nil,
+		owners:   make(map[uint32]string),
+		peers:    make(map[string]struct{}),
+	}
+
+	r.Add(peers...)
+
+	return r
+}
+
+func hash(s string) uint32 {
+	return crc32.ChecksumIEEE([]byte(s))
+}
+
+// Add puts the peers on the ring, the ones already there are left as they are.
+func (r *Ring) Add(peers ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range peers {
+		if _, ok := r.peers[p]; ok {
+			continue
+		}
+
+		r.peers[p] = struct{}{}
+
+		for i := range r.replicas {
+			h := hash(strconv.Itoa(i) + p)
+
+			// on a collision the first peer keeps the point
+			if _, ok := r.owners[h]; ok {
+				continue
+			}
+
+			r.owners[h] = p
+			r.hashes = append(r.hashes, h)
+		}
+	}
+
+	slices.Sort(r.hashes)
+}
+
+// Remove takes the peer off the ring.
+func (r *Ring) Remove(peer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.peers[peer]; !ok {
+		return
+	}
+
+	delete(r.peers, peer)
+
+	r.hashes = slices.DeleteFunc(r.hashes, func(h uint32) bool {
+		if r.owners[h] != peer {
+			return false
+		}
+
+		delete(r.owners, h)
+
+		return true
+	})
+}
+
+// Peers returns the peers on the ring, sorted.
+func (r *Ring) Peers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	peers := make([]string, 0, len(r.peers))
+	for p := range r.peers {
+		peers = append(peers, p)
+	}
+
+	slices.Sort(peers)
+
+	return peers
+}
+
+// Get returns the peer owning key, or an empty string if the ring is empty.
+func (r *Ring) Get(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+
+	// the first point after the hash, wrapping around to the first one
+	i, _ := slices.BinarySearch(r.hashes, h)
+	if i == len(r.hashes) {
+		i = 0
+	}
+
+	return r.owners[r.hashes[i]]
+}
@@ -0,0 +1,106 @@
+package sieve
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPinLimit is returned when pinning one more key would go over the limit set with WithMaxPinned.
+	ErrPinLimit = errors.New("sieve: too many pinned keys")
+	// ErrNoVictim is returned when the sieve is full and the hand finds nothing to evict.
+	ErrNoVictim = errors.New("sieve: no key can be evicted")
+)
+
+// WithMaxPinned is a builder function used to limit how many keys can be pinned at the same time.
+// The limit must be lower than the size of the sieve, so that the hand always finds a key to evict,
+// it defaults to the size minus one.
+// If the limit is negative or not lower than the size, it panics, so for values coming from configuration
+// use NewWithOptions with the WithMaxPinned option, which returns a ConfigError instead.
+func (s *Cache[K, V]) WithMaxPinned(limit int32) *Cache[K, V] {
+	if limit < 0 || limit >= s.capacity {
+		panic(maxPinnedError().Error())
+	}
+
+	s.maxPinned = limit
+
+	return s
+}
+
+// Pin makes the hand skip the key, so that it is never evicted.
+// A pinned key still expires and can be removed with Delete or an invalidation.
+// It returns ErrNotFound if the key does not exist and ErrPinLimit if too many keys are pinned.
+func (s *Cache[K, V]) Pin(key K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.lookup(key, s.now())
+	if !ok {
+		return ErrNotFound
+	}
+
+	return s.pin(n)
+}
+
+// Unpin lets the hand evict the key again.
+// It returns true if the key was pinned.
+func (s *Cache[K, V]) Unpin(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.m[key]
+	if !ok || !n.pinned {
+		return false
+	}
+
+	n.pinned = false
+	s.pinned.Add(-1)
+
+	return true
+}
+
+// SetPinned inserts a key-value pair like Set and pins it.
+// It returns ErrPinLimit, without writing anything, if too many keys are pinned.
+func (s *Cache[K, V]) SetPinned(key K, value V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n, ok := s.m[key]; (!ok || !n.pinned) && s.pinned.Load() >= s.maxPinned {
+		return ErrPinLimit
+	}
+
+	n := s.set(key, value, time.Time{})
+	if n == nil {
+		return ErrNoVictim
+	}
+
+	return s.pin(n)
+}
+
+// TrySet inserts a key-value pair like Set, but it returns ErrNoVictim if the sieve is full
+// and the hand finds nothing to evict, where Set drops the key silently.
+func (s *Cache[K, V]) TrySet(key K, value V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.set(key, value, time.Time{}) == nil {
+		return ErrNoVictim
+	}
+
+	return nil
+}
+
+func (s *Cache[K, V]) pin(n *node[K, V]) error {
+	if n.pinned {
+		return nil
+	}
+
+	if s.pinned.Load() >= s.maxPinned {
+		return ErrPinLimit
+	}
+
+	n.pinned = true
+	s.pinned.Add(1)
+
+	return nil
+}
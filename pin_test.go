@@ -0,0 +1,195 @@
+package sieve
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	s := New[int, string](3)
+
+	s.Set(1, "one")
+	s.Set(2, "two")
+	s.Set(3, "three")
+
+	if err := s.Pin(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 is the next victim but it is pinned
+	s.Set(4, "four")
+
+	if !s.Contains(1) || s.Contains(2) {
+		t.Errorf("expected 2 to be evicted instead of 1, got %s", s)
+	}
+
+	for i := 5; i < 10; i++ {
+		s.Set(i, "v")
+	}
+
+	if !s.Contains(1) {
+		t.Errorf("expected pinned 1 to survive, got %s", s)
+	}
+
+	if !s.Unpin(1) || s.Unpin(1) {
+		t.Errorf("expected Unpin to report the pinned key once")
+	}
+
+	// the others are visited, so the hand goes around to 1
+	s.Get(8)
+	s.Get(9)
+	s.Set(10, "v")
+
+	if s.Contains(1) {
+		t.Errorf("expected 1 to be evicted once unpinned, got %s", s)
+	}
+
+	if err := s.Pin(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPinLimit(t *testing.T) {
+	s := New[int, string](3)
+
+	for i := range 3 {
+		s.Set(i, "v")
+	}
+
+	if err := s.Pin(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetPinned(1, "w"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the default limit is the size minus one
+	if err := s.Pin(2); !errors.Is(err, ErrPinLimit) {
+		t.Errorf("expected ErrPinLimit, got %v", err)
+	}
+
+	if err := s.SetPinned(3, "v"); !errors.Is(err, ErrPinLimit) || s.Contains(3) {
+		t.Errorf("expected ErrPinLimit without writing, got %v", err)
+	}
+
+	// pinning again a pinned key is fine
+	if err := s.SetPinned(1, "x"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if s.Stats().Pinned != 2 {
+		t.Errorf("expected 2 pinned keys, got %d", s.Stats().Pinned)
+	}
+
+	// a deleted key gives its pin back
+	s.Delete(0)
+
+	if err := s.Pin(2); err != nil {
+		t.Errorf("expected room for one more pin, got %v", err)
+	}
+
+	s.Flush()
+
+	if s.Stats().Pinned != 0 {
+		t.Errorf("expected no pinned keys after Flush, got %d", s.Stats().Pinned)
+	}
+}
+
+func TestPinnedVisitedBit(t *testing.T) {
+	s := New[int, string](3)
+
+	s.Set(1, "one")
+	s.Set(2, "two")
+	s.Set(3, "three")
+	s.Get(1)
+	s.Get(3)
+
+	if err := s.Pin(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// the hand moves over 1 clearing its visited bit, even if pinned
+	s.Set(4, "four")
+
+	if s.Contains(2) || s.m[1].visited {
+		t.Errorf("expected 2 evicted and the bit of 1 cleared, got %s", s)
+	}
+
+	// without a match for 3, the hand keeps its visited bit
+	s.Get(4)
+	s.hand = s.m[3]
+
+	if !s.evictWhere(func(n *node[int, string]) bool { return n.key != 3 }) || s.Contains(4) || !s.m[3].visited {
+		t.Errorf("expected 4 evicted and the bit of 3 kept, got %s", s)
+	}
+}
+
+func TestWithMaxPinned(t *testing.T) {
+	s := New[int, string](3).WithMaxPinned(1)
+
+	s.Set(1, "one")
+	s.Set(2, "two")
+
+	if err := s.Pin(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Pin(2); !errors.Is(err, ErrPinLimit) {
+		t.Errorf("expected ErrPinLimit, got %v", err)
+	}
+
+	for _, limit := range []int32{-1, 3} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic with limit %d", limit)
+				}
+			}()
+
+			New[int, string](3).WithMaxPinned(limit)
+		}()
+	}
+}
+
+func TestTrySetNoVictim(t *testing.T) {
+	s := New[int, string](2)
+
+	s.Set(1, "one")
+	s.Set(2, "two")
+	s.Get(1)
+
+	// go around the limit to fill the sieve with pinned keys
+	for _, n := range s.m {
+		n.pinned = true
+		s.pinned.Add(1)
+	}
+
+	if err := s.TrySet(3, "three"); !errors.Is(err, ErrNoVictim) {
+		t.Errorf("expected ErrNoVictim, got %v", err)
+	}
+
+	s.Set(3, "three")
+
+	if s.Contains(3) || s.Len() != 2 {
+		t.Errorf("expected Set to drop the key, got %s", s)
+	}
+
+	// under the pin limit, there is still no room
+	s.maxPinned = 3
+
+	if err := s.SetPinned(3, "three"); !errors.Is(err, ErrNoVictim) || s.Contains(3) {
+		t.Errorf("expected ErrNoVictim, got %v", err)
+	}
+
+	// updating a key needs no victim
+	if err := s.TrySet(1, "uno"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	s.Unpin(2)
+
+	if err := s.TrySet(3, "three"); err != nil || s.Contains(2) {
+		t.Errorf("expected 2 to be evicted, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package sieve
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,7 +17,30 @@ type node[K comparable, V any] struct {
 	next *node[K, V]
 
 	visited bool
-	access  time.Time
+	// absent is true for the negative entries stored by SetNotFound.
+	absent bool
+	// pinned nodes are skipped by the hand, see Pin.
+	pinned bool
+	access time.Time
+
+	// extra is nil until the node needs one of its fields, so that a plain sieve pays one pointer for them.
+	extra *nodeExtra[K, V]
+}
+
+// nodeExtra holds the fields of a node used only by some of the features.
+type nodeExtra[K comparable, V any] struct {
+	// expiresAt is the deadline set by SetWithTTL, zero when the node has none.
+	expiresAt time.Time
+	// written is the time of the last write, the stale-while-revalidate deadlines count from it.
+	// It is only kept with WithStaleWhileRevalidate.
+	written time.Time
+	// refreshing is true while a refresh of the node is running.
+	refreshing bool
+	// tags are the tags set by SetWithTags.
+	tags []string
+	// ns is the namespace holding the node, nil for the keys of the sieve itself, gen its generation at the last write.
+	ns  *namespace[K, V]
+	gen uint64
 }
 
 func (n *node[K, V]) withTTL(now time.Time) *node[K, V] {
@@ -32,8 +56,74 @@ func newNode[K comparable, V any](key K, value V) *node[K, V] {
 		prev:    nil,
 		next:    nil,
 		visited: false,
+		absent:  false,
+		pinned:  false,
 		access:  time.Time{},
+		extra:   nil,
+	}
+}
+
+// ext returns the extra fields of n, allocating them on the first call.
+func (n *node[K, V]) ext() *nodeExtra[K, V] {
+	if n.extra == nil {
+		n.extra = &nodeExtra[K, V]{
+			expiresAt:  time.Time{},
+			written:    time.Time{},
+			refreshing: false,
+			tags:       nil,
+			ns:         nil,
+			gen:        0,
+		}
+	}
+
+	return n.extra
+}
+
+// setTimes sets the deadline and the write time of n, without allocating the extra fields when both are zero.
+func (n *node[K, V]) setTimes(expiresAt, written time.Time) {
+	if n.extra == nil && expiresAt.IsZero() && written.IsZero() {
+		return
+	}
+
+	e := n.ext()
+	e.expiresAt = expiresAt
+	e.written = written
+}
+
+func (n *node[K, V]) expiresAt() time.Time {
+	if n.extra == nil {
+		return time.Time{}
+	}
+
+	return n.extra.expiresAt
+}
+
+func (n *node[K, V]) written() time.Time {
+	if n.extra == nil {
+		return time.Time{}
+	}
+
+	return n.extra.written
+}
+
+func (n *node[K, V]) refreshing() bool {
+	return n.extra != nil && n.extra.refreshing
+}
+
+func (n *node[K, V]) tags() []string {
+	if n.extra == nil {
+		return nil
+	}
+
+	return n.extra.tags
+}
+
+func (n *node[K, V]) ns() *namespace[K, V] {
+	if n.extra == nil {
+		return nil
 	}
+
+	return n.extra.ns
 }
 
 // Cache is a data structure working as a cache with a fixed size.
@@ -43,16 +133,64 @@ type Cache[K comparable, V any] struct {
 	// hand is a pointer to the current node that is going to be evicted.
 	hand *node[K, V]
 
-	// m is a map that holds the key-value pairs.
+	// m is a map that holds the key-value pairs, the ones of the namespaces are in their own maps.
 	m map[K]*node[K, V]
 
 	capacity int32
 	len      atomic.Int32
 	ttl      time.Duration
 
+	// stale-while-revalidate, see WithStaleWhileRevalidate.
+	softTTL      time.Duration
+	hardTTL      time.Duration
+	refreshAhead time.Duration
+	refresh      func(key K) (V, error)
+
+	// negativeTTL is the default TTL of the entries stored by SetNotFound.
+	negativeTTL time.Duration
+
+	// tags indexes the nodes by tag, see SetWithTags.
+	tags map[string]map[*node[K, V]]struct{}
+	// prefixes indexes the nodes by key, only for string keys, see WithPrefixIndex.
+	prefixes *trie[K, V]
+
+	// namespaces holds the namespaces by name, see Namespace.
+	namespaces map[string]*namespace[K, V]
+
+	// pinned is the number of pinned nodes, at most maxPinned.
+	pinned    atomic.Int32
+	maxPinned int32
+
+	// logger logs the rare events, nil if WithLogger was not called.
+	logger *eventLogger
+
+	// maxSweep caps the nodes the hand moves over for one eviction, zero means no cap.
+	maxSweep int
+
+	// onEvict is called for every node removed, see OnEvict.
+	onEvict func(key K, value V, reason EvictReason)
+
+	// journal records the changes of the sieve, nil if OpenWAL was not called.
+	journal journal[K, V]
+
+	// clock replaces now, see WithClock.
+	clock func() time.Time
+
+	stats stats
+
 	mu sync.Locker
 }
 
+// WithMaxSweep is a builder function used to bound the work of one eviction.
+// After moving over steps nodes the hand evicts the node it is on, even if visited,
+// trading a bit of hit ratio for a bounded pause under the lock on large sieves.
+// Pinned nodes are still skipped. A steps less than or equal to zero means no bound.
+func (s *Cache[K, V]) WithMaxSweep(steps int) *Cache[K, V] {
+	s.maxSweep = max(steps, 0)
+
+	return s
+}
+
 // WithTTL is a builder function used to add the expiration management for keys.
 func (s *Cache[K, V]) WithTTL(ttl time.Duration) *Cache[K, V] {
 	s.ttl = ttl
@@ -62,29 +200,22 @@ func (s *Cache[K, V]) WithTTL(ttl time.Duration) *Cache[K, V] {
 
 // New returns a new sieve.
 // The size parameter is the maximum number of elements that the sieve can hold.
-// If the size is less than or equal to zero, it panics.
+// If the size is less than or equal to zero, it panics, see NewWithOptions to get an error instead.
 func New[K comparable, V any](size int32) *Cache[K, V] {
-	if size <= 0 {
-		panic("sieve: size must be greater than zero")
+	c, err := NewWithOptions[K, V](WithSize(size))
+	if err != nil {
+		panic(err.Error())
 	}
 
-	return &Cache[K, V]{
-		head:     nil,
-		tail:     nil,
-		hand:     nil,
-		m:        make(map[K]*node[K, V]),
-		capacity: size,
-		len:      atomic.Int32{},
-		ttl:      0,
-		mu:       &sync.Mutex{},
-	}
+	return c
 }
 
 // NewSingleThread returns a new sieve that is safe for single-threaded use.
 func NewSingleThread[K comparable, V any](size int32) *Cache[K, V] {
-	c := New[K, V](size)
-
-	c.mu = noopMutex{}
+	c, err := NewWithOptions[K, V](WithSize(size), WithSingleThread())
+	if err != nil {
+		panic(err.Error())
+	}
 
 	return c
 }
@@ -96,6 +227,7 @@ func (s *Cache[K, V]) Len() int32 {
 
 // Set inserts a new key-value pair in the sieve.
 // If the key already exists, it does nothing.
+// If the sieve is full of pinned keys the pair is dropped, see TrySet.
 // The order of the insert will be something like:
 // [head] -> [node] -> [node] -> ... -> [tail]
 // The hand pointer is moving from the tail to the head.
@@ -104,10 +236,51 @@ func (s *Cache[K, V]) Set(key K, value V) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	atNow := now()
+	s.set(key, value, time.Time{})
+}
+
+// SetWithTTL inserts a key-value pair that expires after ttl, regardless of how often it is read.
+// It works alongside WithTTL: the entry is gone as soon as either deadline is reached.
+// A ttl less than or equal to zero means the entry has no deadline of its own.
+func (s *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = s.now().Add(ttl)
+	}
+
+	s.set(key, value, expiresAt)
+}
+
+// SetMany inserts all the key-value pairs taking the lock once.
+// The eviction is the same as calling Set for each pair, in the iteration order of the map.
+func (s *Cache[K, V]) SetMany(values map[K]V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range values {
+		s.set(k, v, time.Time{})
+	}
+}
+
+func (s *Cache[K, V]) set(key K, value V, expiresAt time.Time) *node[K, V] {
+	return s.setIn(nil, key, value, expiresAt)
+}
+
+// setIn is set in the keys of ns, or in the ones of the sieve itself if ns is nil.
+func (s *Cache[K, V]) setIn(ns *namespace[K, V], key K, value V, expiresAt time.Time) *node[K, V] {
+	atNow := s.now()
+
+	// only the stale-while-revalidate deadlines need the write time
+	var written time.Time
+	if s.hardTTL > 0 {
+		written = atNow
+	}
 
 	// key already exists
-	if v, ok := s.m[key]; ok {
+	if v, ok := s.keyspace(ns)[key]; ok {
 		// mark the node visited
 		v.visited = true
 
@@ -117,12 +290,27 @@ func (s *Cache[K, V]) Set(key K, value V) {
 		// update the access time
 		v.access = atNow
 
-		return
+		// the new value brings its own deadline
+		v.setTimes(expiresAt, written)
+
+		// a value replaces a negative entry
+		v.absent = false
+
+		s.untag(v)
+
+		// and belongs to the current generation of its namespace
+		if ns != nil {
+			v.extra.gen = ns.gen
+		}
+
+		s.logSet(v)
+
+		return v
 	}
 
 	// cache is full
-	if s.Len() == s.capacity {
-		s.evictNode()
+	if s.Len() == s.capacity && !s.evictNode() {
+		return nil
 	}
 
 	n := newNode(key, value)
@@ -131,8 +319,29 @@ func (s *Cache[K, V]) Set(key K, value V) {
 		n = n.withTTL(atNow)
 	}
 
+	n.setTimes(expiresAt, written)
+
+	if ns != nil {
+		e := n.ext()
+		e.ns = ns
+		e.gen = ns.gen
+	}
+
+	s.pushHead(n)
+
+	s.logSet(n)
+
+	return n
+}
+
+// pushHead inserts a new node at the head of the list and in the map of its namespace.
+func (s *Cache[K, V]) pushHead(n *node[K, V]) {
 	// insert into the cache
-	s.m[key] = n
+	s.keyspace(n.ns())[n.key] = n
+
+	if s.prefixes != nil && n.ns() == nil {
+		s.prefixes.insert(n)
+	}
 
 	s.len.Add(1)
 
@@ -157,20 +366,80 @@ func (s *Cache[K, V]) Set(key K, value V) {
 	}
 }
 
-func (s *Cache[K, V]) evictNode() {
+// expired reports whether the node outlived the cache TTL, the hard TTL, its own deadline
+// or the generation of its namespace.
+func (s *Cache[K, V]) expired(n *node[K, V], atNow time.Time) bool {
+	if ns := n.ns(); ns != nil && n.extra.gen != ns.gen {
+		return true
+	}
+
+	if s.ttl > 0 && atNow.Sub(n.access) > s.ttl {
+		return true
+	}
+
+	if s.hardTTL > 0 && atNow.Sub(n.written()) > s.hardTTL {
+		return true
+	}
+
+	// the refresh only knows the key, so the keys of a namespace are never refreshed and expire when stale
+	if n.ns() != nil && s.softTTL > 0 && atNow.Sub(n.written()) > s.softTTL {
+		return true
+	}
+
+	expiresAt := n.expiresAt()
+
+	return !expiresAt.IsZero() && atNow.After(expiresAt)
+}
+
+// evictNode removes one node to make room for a new one.
+// It returns false if every node is pinned, so there is nothing to evict.
+func (s *Cache[K, V]) evictNode() bool {
+	return s.evictWhere(nil)
+}
+
+// evictWhere is evictNode choosing the victim among the nodes matching match, or among all of them if match is nil.
+// The hand clears the visited bits of the matching nodes it moves over, the pinned ones included as in SIEVE,
+// but leaves the bits of the other nodes, so the evictions of the write-ahead log cannot replay it.
+func (s *Cache[K, V]) evictWhere(match func(n *node[K, V]) bool) bool {
 	h := s.hand
 
-	for atNow := now(); h.visited; {
+	atNow := s.now()
+
+	// swept counts the nodes the hand moved over, cleared the visited bits cleared among them
+	swept, cleared := 0, 0
+
+	// after two laps every node that is not pinned has lost its visited bit
+	for steps := 2 * s.Len(); h.visited || h.pinned || (match != nil && !match(h)); steps-- {
+		candidate := match == nil || match(h)
+
 		// if the node is visited but is expired, then we can evict it
-		if s.ttl > 0 && atNow.Sub(h.access) > s.ttl {
+		if candidate && s.expired(h, atNow) {
+			break
+		}
+
+		// the sweep is long enough, the node under the hand goes even if visited
+		if candidate && s.maxSweep > 0 && swept >= s.maxSweep && !h.pinned {
 			break
 		}
 
-		// don't evict the node, just mark it as not visited
-		h.visited = false
+		if steps == 0 {
+			s.hand = h
+
+			return false
+		}
+
+		if candidate {
+			if h.visited {
+				cleared++
+			}
+
+			// don't evict the node, just mark it as not visited
+			h.visited = false
+		}
 
 		// move hand towards the head
 		h = h.prev
+		swept++
 
 		// wrap around if we go beyond the head
 		if h == nil {
@@ -178,132 +447,320 @@ func (s *Cache[K, V]) evictNode() {
 		}
 	}
 
-	s.hand = h.prev
+	reason := Evicted
 
-	// wrap to the end if we go beyond the head
-	if s.hand == nil {
-		s.hand = s.tail
-	}
+	if s.expired(h, atNow) {
+		reason = Expired
 
-	if h.next != nil {
-		h.next.prev = h.prev
-	} else { // so we are the last node
-		s.tail = h.prev
+		s.stats.expirations.Add(1)
+	} else {
+		s.stats.evictions.Add(1)
 	}
 
-	if h.prev != nil {
-		h.prev.next = h.next
-	}
+	s.stats.sweeps.observe(int64(swept))
 
-	if s.head == h {
-		s.head = h.next
+	// most of the sieve was visited since the last lap, the eviction cost as much as a full scan
+	if cleared > int(s.capacity)/2 {
+		s.logger.log("sieve: long hand sweep", slog.Int("cleared", cleared), slog.Int("len", int(s.Len())))
 	}
 
-	delete(s.m, h.key)
+	// the hand restarts from the node after the victim
+	s.hand = h
 
-	s.len.Add(-1)
+	s.logEvict(h)
+	s.dropNode(h, reason)
+
+	return true
 }
 
-func (s *Cache[K, V]) removeNodeFromLinkedList(n *node[K, V]) {
-	l := s.Len()
+// removeNode drops n from the linked list and from the map, and tells OnEvict why.
+func (s *Cache[K, V]) removeNode(n *node[K, V], reason EvictReason) {
+	s.logRemove(n)
+	s.dropNode(n, reason)
+}
 
-	// help the GC to collect the node
-	defer func() {
-		n.prev = nil
-		n.next = nil
-	}()
+// dropNode is removeNode without the write-ahead log, the hand already logged its victims.
+func (s *Cache[K, V]) dropNode(n *node[K, V], reason EvictReason) {
+	s.removeNodeFromLinkedList(n)
 
-	if l == 1 {
-		// just reset everything
-		s.hand = nil
-		s.head = nil
-		s.tail = nil
+	delete(s.keyspace(n.ns()), n.key)
 
-		return
+	s.untag(n)
+
+	if s.prefixes != nil && n.ns() == nil {
+		s.prefixes.remove(n)
 	}
 
-	if l == 2 {
-		if s.head == n {
-			s.head = s.tail
-		} else { // so n == s.tail
-			s.tail = s.head
-		}
+	if n.pinned {
+		s.pinned.Add(-1)
+	}
 
-		s.hand = s.tail
+	s.len.Add(-1)
 
-		return
+	if s.onEvict != nil {
+		s.onEvict(n.key, n.value, reason)
 	}
+}
 
-	// remove from the linked list
+func (s *Cache[K, V]) removeNodeFromLinkedList(n *node[K, V]) {
+	// the hand keeps moving towards the head
+	if s.hand == n {
+		s.hand = n.prev
+	}
 
-	switch n {
-	case s.head:
-		n.next.prev = nil
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else { // so n is the head
 		s.head = n.next
-	case s.tail:
-		if s.hand == n {
-			s.hand = n.prev
-		}
+	}
 
-		n.prev.next = nil
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else { // so n is the tail
 		s.tail = n.prev
-	default:
-		if s.hand == n {
-			s.hand = n.prev
-		}
+	}
 
-		n.prev.next = n.next
-		n.next.prev = n.prev
+	// wrap to the end if we go beyond the head
+	if s.hand == nil {
+		s.hand = s.tail
+	}
+
+	// help the GC to collect the node
+	n.prev = nil
+	n.next = nil
+}
+
+// lookup returns the node of key, removing it first if it is expired.
+func (s *Cache[K, V]) lookup(key K, atNow time.Time) (*node[K, V], bool) {
+	return s.lookupIn(nil, key, atNow)
+}
+
+// lookupIn is lookup in the keys of ns.
+func (s *Cache[K, V]) lookupIn(ns *namespace[K, V], key K, atNow time.Time) (*node[K, V], bool) {
+	n, ok := s.keyspace(ns)[key]
+	if !ok {
+		return nil, false
+	}
+
+	if s.expired(n, atNow) {
+		s.removeNode(n, Expired)
+
+		s.stats.expirations.Add(1)
+
+		return nil, false
 	}
+
+	return n, true
 }
 
 // Get returns the value associated with the key.
 // If the key does not exist, it returns zero value an false, otherwise the value and true.
+// A key stored by SetNotFound does not exist for Get, see Lookup.
 func (s *Cache[K, V]) Get(key K) (V, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var zeroValue V
+	v, res := s.get(key, s.now())
 
-	n, ok := s.m[key]
+	return v, res == Hit
+}
+
+func (s *Cache[K, V]) get(key K, atNow time.Time) (V, Result) {
+	return s.getIn(nil, key, atNow)
+}
+
+// getIn is get in the keys of ns.
+func (s *Cache[K, V]) getIn(ns *namespace[K, V], key K, atNow time.Time) (V, Result) {
+	var zeroValue V
 
+	n, ok := s.lookupIn(ns, key, atNow)
 	if !ok {
-		return zeroValue, false
+		s.stats.misses.Add(1)
+
+		return zeroValue, Miss
 	}
 
-	atNow := now()
+	// update the access time
+	n.access = atNow
 
-	if s.ttl > 0 && atNow.Sub(n.access) > s.ttl {
-		s.removeNodeFromLinkedList(n)
+	s.logVisit(n, atNow)
 
-		// remove the node from the cache
-		delete(s.m, n.key)
+	if n.absent {
+		s.stats.negativeHits.Add(1)
 
-		// decrease length
-		s.len.Add(-1)
+		n.visited = true
 
-		return zeroValue, false
+		return zeroValue, Absent
 	}
 
-	// update the access time
-	n.access = atNow
+	s.stats.hits.Add(1)
+
+	if s.refresh != nil && n.ns() == nil && !n.refreshing() && s.shouldRefresh(n, atNow) {
+		n.ext().refreshing = true
+
+		go s.refreshNode(n, n.written())
+	}
 
 	// mark the node as visited
 	n.visited = true
 
+	return n.value, Hit
+}
+
+// GetMany returns the values of the keys found in the sieve and the keys that are missing.
+// The lock is taken once and the keys are looked up in order, so the result is the same as calling Get for each key.
+// The keys stored by SetNotFound are in neither of the two, since there is nothing to load for them.
+func (s *Cache[K, V]) GetMany(keys []K) (map[K]V, []K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	atNow := s.now()
+
+	values := make(map[K]V, len(keys))
+
+	var missing []K
+
+	for _, k := range keys {
+		switch v, res := s.get(k, atNow); res {
+		case Hit:
+			values[k] = v
+		case Miss:
+			missing = append(missing, k)
+		case Absent:
+		}
+	}
+
+	return values, missing
+}
+
+// Contains reports whether the key is in the sieve, the keys stored by SetNotFound are not.
+// Unlike Get, it does not mark the key as visited and it is not counted in the stats.
+func (s *Cache[K, V]) Contains(key K) bool {
+	_, ok := s.Peek(key)
+
+	return ok
+}
+
+// Peek returns the value associated with the key like Get,
+// but it does not mark the key as visited and it is not counted in the stats.
+func (s *Cache[K, V]) Peek(key K) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.lookup(key, s.now())
+	if !ok || n.absent {
+		var zeroValue V
+
+		return zeroValue, false
+	}
+
 	return n.value, true
 }
 
+// Delete removes the key from the sieve.
+// It returns true if the key was present.
+func (s *Cache[K, V]) Delete(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.m[key]
+	if !ok {
+		return false
+	}
+
+	s.removeNode(n, Deleted)
+
+	return true
+}
+
 // Flush removes all elements from the sieve and dealloc the internal structs.
 func (s *Cache[K, V]) Flush() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.reset()
+
+	s.logFlush()
+}
+
+func (s *Cache[K, V]) reset() {
 	s.head = nil
 	s.tail = nil
 	s.hand = nil
 	s.m = make(map[K]*node[K, V])
-	s.len = atomic.Int32{}
+	s.len.Store(0)
+	s.tags = nil
+	s.pinned.Store(0)
+
+	// the namespaces stay, the handles returned by Namespace point to them
+	for _, ns := range s.namespaces {
+		ns.m = make(map[K]*node[K, V])
+	}
+
+	if s.prefixes != nil {
+		s.prefixes = newTrie[K, V]()
+	}
+}
+
+// Stats holds the counters of a sieve since it was created.
+type Stats struct {
+	// Hits is the number of Get calls that found the key.
+	Hits uint64
+	// Misses is the number of Get calls that did not find the key, including expired ones.
+	Misses uint64
+	// NegativeHits is the number of Get calls that found a key stored by SetNotFound.
+	NegativeHits uint64
+	// Evictions is the number of keys removed to make room for a new one.
+	Evictions uint64
+	// Expirations is the number of keys removed because they were expired.
+	Expirations uint64
+	// Pinned is the number of pinned keys.
+	Pinned int32
+	// LoadLatency is the distribution of the loads done by LoadingCache, BatchLoader and the refreshes.
+	LoadLatency Histogram[time.Duration]
+	// SweepLength is the distribution of the number of nodes the hand moved over to find each victim.
+	SweepLength Histogram[int64]
+
+	Len      int32
+	Capacity int32
+}
+
+type stats struct {
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	negativeHits atomic.Uint64
+	evictions    atomic.Uint64
+	expirations  atomic.Uint64
+	loads        *histogram[time.Duration]
+	sweeps       *histogram[int64]
+}
+
+func newStats() stats {
+	return stats{
+		hits:         atomic.Uint64{},
+		misses:       atomic.Uint64{},
+		negativeHits: atomic.Uint64{},
+		evictions:    atomic.Uint64{},
+		expirations:  atomic.Uint64{},
+		loads:        newHistogram(loadBounds),
+		sweeps:       newHistogram(sweepBounds),
+	}
+}
+
+// Stats returns a snapshot of the counters of the sieve.
+func (s *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:         s.stats.hits.Load(),
+		Misses:       s.stats.misses.Load(),
+		NegativeHits: s.stats.negativeHits.Load(),
+		Evictions:    s.stats.evictions.Load(),
+		Expirations:  s.stats.expirations.Load(),
+		Pinned:       s.pinned.Load(),
+		LoadLatency:  s.stats.loads.snapshot(),
+		SweepLength:  s.stats.sweeps.snapshot(),
+		Len:          s.Len(),
+		Capacity:     s.capacity,
+	}
 }
 
 type noopMutex struct{}
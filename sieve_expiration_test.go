@@ -312,6 +312,147 @@ func TestSetWithAllExpired(t *testing.T) {
 	}
 }
 
+func TestSetWithTTL(t *testing.T) {
+	s := New[int, struct{}](4)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.SetWithTTL(7, struct{}{}, 2*time.Second)
+	s.Set(8, struct{}{})
+
+	sec = 2
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	if _, ok := s.Get(7); !ok {
+		t.Errorf("expected key 7 to be in the cache")
+	}
+
+	// reading does not extend a per-entry deadline
+	sec = 4
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	if _, ok := s.Get(7); ok {
+		t.Errorf("expected key 7 to be expired")
+	}
+
+	if _, ok := s.Get(8); !ok {
+		t.Errorf("expected key 8 to be in the cache")
+	}
+
+	if s.Len() != 1 {
+		t.Errorf("expected len to be 1, got %d", s.Len())
+	}
+}
+
+func TestSetWithTTLOverridesDeadline(t *testing.T) {
+	s := New[int, struct{}](4)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.SetWithTTL(7, struct{}{}, 1*time.Second)
+	s.Set(7, struct{}{}) // drop the deadline
+
+	s.SetWithTTL(8, struct{}{}, 1*time.Second)
+	s.SetWithTTL(8, struct{}{}, 10*time.Second) // push the deadline
+
+	sec = 5
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	if _, ok := s.Get(7); !ok {
+		t.Errorf("expected key 7 to be in the cache")
+	}
+
+	if _, ok := s.Get(8); !ok {
+		t.Errorf("expected key 8 to be in the cache")
+	}
+}
+
+func TestSetWithTTLEvictsExpiredFirst(t *testing.T) {
+	s := New[int, struct{}](2)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.SetWithTTL(7, struct{}{}, 1*time.Second)
+	s.Set(8, struct{}{})
+	s.Get(7)
+	s.Get(8)
+
+	sec = 3
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	// 7 is visited but expired, so it is the victim
+	s.Set(9, struct{}{})
+
+	if expected := `[9: {} -> 8: {}]`; s.String() != expected {
+		t.Errorf("expected %s, got %s", expected, s.String())
+	}
+}
+
+func TestExpiredHeadUnderHand(t *testing.T) {
+	s := New[int, struct{}](3).WithTTL(1 * time.Second)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(7, struct{}{})
+	s.Set(8, struct{}{})
+
+	sec = 2
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(9, struct{}{})
+
+	// the hand can sit on the head after an eviction sweep
+	s.hand = s.head
+
+	sec = 4
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	if _, ok := s.Get(9); ok {
+		t.Errorf("expected key 9 to be expired")
+	}
+
+	if s.hand != s.tail {
+		t.Errorf("expected hand to wrap to the tail")
+	}
+
+	s.Set(10, struct{}{})
+	s.Set(11, struct{}{})
+
+	if expected := `[11: {} -> 10: {} -> 8: {}]`; s.String() != expected {
+		t.Errorf("expected %s, got %s", expected, s.String())
+	}
+}
+
+func TestExpirationStats(t *testing.T) {
+	s := New[int, struct{}](2).WithTTL(1 * time.Second)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(7, struct{}{})
+	s.Set(8, struct{}{})
+
+	sec = 3
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Get(7)              // expired on get
+	s.Set(9, struct{}{})  // room left, no eviction
+	s.Set(10, struct{}{}) // 8 is expired and evicted
+
+	if s.Contains(8) {
+		t.Errorf("expected key 8 to be gone")
+	}
+
+	stats := s.Stats()
+	if stats.Expirations != 2 || stats.Evictions != 0 || stats.Misses != 1 {
+		t.Errorf("expected 2 expirations, 0 evictions and 1 miss, got %+v", stats)
+	}
+}
+
 func BenchmarkSimpleWithTTL(b *testing.B) {
 	b.ReportAllocs()
 
@@ -3,7 +3,9 @@ package sieve_test
 import (
 	"bufio"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/guerinoni/sieve"
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -227,6 +229,219 @@ func TestMoreComplex(t *testing.T) { //nolint: dupl
 	}
 }
 
+func TestDelete(t *testing.T) {
+	s := sieve.New[int, string](3)
+
+	s.Set(1, one)
+	s.Set(2, "two")
+	s.Set(3, "three")
+
+	if !s.Delete(2) {
+		t.Errorf("expected key 2 to be deleted")
+	}
+
+	if s.Delete(2) {
+		t.Errorf("expected key 2 to be already deleted")
+	}
+
+	if s.Len() != 2 {
+		t.Errorf("expected length 2, got %d", s.Len())
+	}
+
+	if _, ok := s.Get(2); ok {
+		t.Errorf("expected key 2 to not exist, but it does")
+	}
+
+	// remove head and tail, the hand must follow
+	s.Delete(3)
+	s.Delete(1)
+
+	if s.Len() != 0 {
+		t.Errorf("expected length 0, got %d", s.Len())
+	}
+
+	s.Set(4, "four")
+	s.Set(5, "five")
+	s.Set(6, "six")
+	s.Set(7, "seven")
+
+	if expected := `[7: seven -> 6: six -> 5: five]`; s.String() != expected {
+		t.Errorf("expected %s, got %s", expected, s.String())
+	}
+}
+
+func TestContains(t *testing.T) {
+	s := sieve.New[int, string](2)
+
+	s.Set(1, one)
+	s.Set(2, "two")
+
+	if !s.Contains(1) {
+		t.Errorf("expected key 1 to exist, but it does not")
+	}
+
+	if s.Contains(3) {
+		t.Errorf("expected key 3 to not exist, but it does")
+	}
+
+	// Contains did not mark 1 as visited, so it is evicted first
+	s.Set(3, "three")
+
+	if s.Contains(1) {
+		t.Errorf("expected key 1 to be evicted")
+	}
+}
+
+func TestPeek(t *testing.T) {
+	s := sieve.New[int, string](2)
+
+	s.Set(1, one)
+	s.Set(2, "two")
+
+	v, ok := s.Peek(1)
+	if !ok || v != one {
+		t.Errorf("expected value 'one' for key 1, got '%s'", v)
+	}
+
+	if _, ok := s.Peek(3); ok {
+		t.Errorf("expected key 3 to not exist, but it does")
+	}
+
+	// Peek did not mark 1 as visited, so it is evicted first
+	s.Set(3, "three")
+
+	if _, ok := s.Peek(1); ok {
+		t.Errorf("expected key 1 to be evicted")
+	}
+
+	if stats := s.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("expected Peek to not be counted, got %+v", stats)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := sieve.New[int, string](2)
+
+	s.Set(1, one)
+	s.Set(2, "two")
+	s.Get(1)
+	s.Get(3)
+	s.Set(3, "three")
+
+	got := s.Stats()
+
+	// nothing was loaded
+	if got.LoadLatency.Count != 0 {
+		t.Errorf("expected no loads, got %+v", got.LoadLatency)
+	}
+
+	got.LoadLatency = sieve.Histogram[time.Duration]{}
+
+	// the hand moved over the visited 1 to evict 2
+	if got.SweepLength.Count != 1 || got.SweepLength.Sum != 1 {
+		t.Errorf("expected one sweep of length 1, got %+v", got.SweepLength)
+	}
+
+	got.SweepLength = sieve.Histogram[int64]{}
+
+	expected := sieve.Stats{Hits: 1, Misses: 1, Evictions: 1, Len: 2, Capacity: 2}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	s := sieve.New[int, string](3)
+	seq := sieve.New[int, string](3)
+
+	for _, c := range []*sieve.Cache[int, string]{s, seq} {
+		c.Set(1, one)
+		c.Set(2, "two")
+		c.Set(3, "three")
+	}
+
+	values, missing := s.GetMany([]int{1, 4, 3, 5})
+
+	for _, k := range []int{1, 4, 3, 5} {
+		seq.Get(k)
+	}
+
+	if len(values) != 2 || values[1] != one || values[3] != "three" {
+		t.Errorf("expected values for 1 and 3, got %v", values)
+	}
+
+	if len(missing) != 2 || missing[0] != 4 || missing[1] != 5 {
+		t.Errorf("expected missing [4 5], got %v", missing)
+	}
+
+	// same visited bits as sequential calls, so the same victims
+	s.Set(6, "six")
+	seq.Set(6, "six")
+
+	if s.String() != seq.String() {
+		t.Errorf("expected %s, got %s", seq.String(), s.String())
+	}
+
+	if !reflect.DeepEqual(s.Stats(), seq.Stats()) {
+		t.Errorf("expected %+v, got %+v", seq.Stats(), s.Stats())
+	}
+}
+
+func TestSetMany(t *testing.T) {
+	s := sieve.New[int, string](3)
+
+	s.Set(1, one)
+	s.Get(1)
+
+	s.SetMany(map[int]string{2: "two", 3: "three", 1: "uno"})
+
+	if s.Len() != 3 {
+		t.Errorf("expected length 3, got %d", s.Len())
+	}
+
+	if v, _ := s.Get(1); v != "uno" {
+		t.Errorf("expected value 'uno' for key 1, got '%s'", v)
+	}
+
+	s.SetMany(map[int]string{4: "four", 5: "five"})
+
+	// 1 is visited, so it survives both evictions
+	values, missing := s.GetMany([]int{1, 4, 5})
+	if len(values) != 3 || len(missing) != 0 {
+		t.Errorf("expected 1, 4 and 5 to exist, got %v", values)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	s := sieve.New[int, int](1000)
+	keys := make([]int, 100)
+
+	for i := range keys {
+		keys[i] = i
+		s.Set(i, i)
+	}
+
+	for b.Loop() {
+		for _, k := range keys {
+			s.Get(k)
+		}
+	}
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	s := sieve.New[int, int](1000)
+	keys := make([]int, 100)
+
+	for i := range keys {
+		keys[i] = i
+		s.Set(i, i)
+	}
+
+	for b.Loop() {
+		s.GetMany(keys)
+	}
+}
+
 func BenchmarkSimple(b *testing.B) {
 	b.ReportAllocs()
 
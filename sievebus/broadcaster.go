@@ -0,0 +1,189 @@
+package sievebus
+
+import (
+	"bufio"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+)
+
+// ErrInUse is returned by Listen when another broadcaster answers on the socket.
+var ErrInUse = errors.New("sievebus: socket in use")
+
+// queueSize is how many messages a subscriber can be behind before it is disconnected.
+const queueSize = 1024
+
+// Broadcaster relays the messages of every subscriber to all of them, numbered in one sequence.
+type Broadcaster struct {
+	l     net.Listener
+	epoch uint64
+
+	mu     sync.Mutex
+	seq    uint64
+	conns  map[*peer]struct{}
+	closed bool
+
+	wg sync.WaitGroup
+}
+
+// peer is a connected subscriber, out is its queue of frames.
+type peer struct {
+	conn net.Conn
+	out  chan []byte
+}
+
+// Listen starts a broadcaster on the unix socket at path, replacing the file left by a broadcaster that died.
+// Each broadcaster picks a new random epoch, so that the subscribers know the sequence started again.
+func Listen(path string) (*Broadcaster, error) {
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+
+		return nil, ErrInUse
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broadcaster{
+		l:      l,
+		epoch:  rand.Uint64() | 1,
+		mu:     sync.Mutex{},
+		seq:    0,
+		conns:  make(map[*peer]struct{}),
+		closed: false,
+		wg:     sync.WaitGroup{},
+	}
+
+	b.wg.Go(b.accept)
+
+	return b, nil
+}
+
+func (b *Broadcaster) accept() {
+	for {
+		conn, err := b.l.Accept()
+		if err != nil {
+			return
+		}
+
+		p := &peer{conn: conn, out: make(chan []byte, queueSize)}
+
+		b.mu.Lock()
+
+		if b.closed {
+			b.mu.Unlock()
+			conn.Close()
+
+			return
+		}
+
+		// the hello goes first in the queue, so the next message is seq+1
+		p.out <- message{kind: kindHello, epoch: b.epoch, seq: b.seq, payload: nil}.encode()
+		b.conns[p] = struct{}{}
+
+		b.mu.Unlock()
+
+		b.wg.Go(func() { b.write(p) })
+		b.wg.Go(func() { b.read(p) })
+	}
+}
+
+// write sends the queue of p until it is closed.
+func (b *Broadcaster) write(p *peer) {
+	for frame := range p.out {
+		if _, err := p.conn.Write(frame); err != nil {
+			p.conn.Close()
+		}
+	}
+
+	p.conn.Close()
+}
+
+// read relays the messages of p until the connection breaks.
+func (b *Broadcaster) read(p *peer) {
+	r := bufio.NewReader(p.conn)
+
+	for {
+		m, err := readMessage(r)
+		if err != nil || m.kind == kindHello {
+			b.drop(p)
+
+			return
+		}
+
+		b.publish(m)
+	}
+}
+
+// publish numbers m and queues it for every subscriber, dropping the ones too far behind:
+// they will see the gap when they reconnect.
+func (b *Broadcaster) publish(m message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	m.epoch = b.epoch
+	m.seq = b.seq
+
+	frame := m.encode()
+
+	for p := range b.conns {
+		select {
+		case p.out <- frame:
+		default:
+			b.dropLocked(p)
+		}
+	}
+}
+
+func (b *Broadcaster) drop(p *peer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dropLocked(p)
+}
+
+func (b *Broadcaster) dropLocked(p *peer) {
+	if _, ok := b.conns[p]; !ok {
+		return
+	}
+
+	delete(b.conns, p)
+	close(p.out)
+}
+
+// Seq returns the sequence number of the last message.
+func (b *Broadcaster) Seq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.seq
+}
+
+// Close disconnects the subscribers and removes the socket.
+func (b *Broadcaster) Close() error {
+	b.mu.Lock()
+
+	b.closed = true
+
+	for p := range b.conns {
+		b.dropLocked(p)
+	}
+
+	b.mu.Unlock()
+
+	// closing a unix listener removes its file
+	err := b.l.Close()
+
+	b.wg.Wait()
+
+	return err
+}
@@ -0,0 +1,82 @@
+// Package sievebus keeps the sieves of the processes of a host coherent: a Broadcaster listens on a unix socket,
+// and the Subscriber of each sieve sends it the keys deleted, the tags invalidated and the flushes,
+// and applies the ones of the other processes.
+//
+// The broadcaster numbers the messages. A subscriber that sees a gap, because it was too slow or disconnected,
+// or a new epoch, because the broadcaster restarted, flushes its sieve since it cannot know what it missed.
+package sievebus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// kind is the kind of a message.
+type kind byte
+
+const (
+	// kindHello is the first message of a connection, with the current epoch and sequence number.
+	kindHello kind = iota + 1
+	kindDelete
+	kindTag
+	kindFlush
+)
+
+const (
+	// header is the length of a frame after the length itself, without the payload: kind, epoch and sequence number.
+	header = 1 + 8 + 8
+	// maxFrame is a sanity limit, a larger length means the peer is not speaking the protocol.
+	maxFrame = 1 << 20
+)
+
+var errFrame = errors.New("sievebus: malformed frame")
+
+// message is a frame: the broadcaster sets epoch and seq, they are zero in the ones sent to it.
+type message struct {
+	kind    kind
+	epoch   uint64
+	seq     uint64
+	payload []byte
+}
+
+func (m message) encode() []byte {
+	b := make([]byte, 4+header+len(m.payload))
+	binary.LittleEndian.PutUint32(b, uint32(header+len(m.payload)))
+	b[4] = byte(m.kind)
+	binary.LittleEndian.PutUint64(b[5:], m.epoch)
+	binary.LittleEndian.PutUint64(b[13:], m.seq)
+	copy(b[4+header:], m.payload)
+
+	return b
+}
+
+func readMessage(r io.Reader) (message, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return message{}, err
+	}
+
+	size := binary.LittleEndian.Uint32(length[:])
+	if size < header || size > maxFrame {
+		return message{}, errFrame
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return message{}, err
+	}
+
+	m := message{
+		kind:    kind(b[0]),
+		epoch:   binary.LittleEndian.Uint64(b[1:]),
+		seq:     binary.LittleEndian.Uint64(b[9:]),
+		payload: b[header:],
+	}
+
+	if m.kind < kindHello || m.kind > kindFlush {
+		return message{}, errFrame
+	}
+
+	return m, nil
+}
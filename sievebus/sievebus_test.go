@@ -0,0 +1,236 @@
+package sievebus
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// socketPath returns a path in a short temporary directory, unix socket paths are limited to about 100 bytes.
+func socketPath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "sievebus")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "bus.sock")
+}
+
+func listen(t *testing.T, path string) *Broadcaster {
+	t.Helper()
+
+	b, err := Listen(path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	t.Cleanup(func() { b.Close() })
+
+	return b
+}
+
+func subscribe(t *testing.T, path string) (*sieve.Cache[string, int], *Subscriber[string, int]) {
+	t.Helper()
+
+	c := sieve.New[string, int](100)
+	s := Subscribe(path, c, sieve.JSONCodec[string]{})
+
+	t.Cleanup(func() { s.Close() })
+
+	eventually(t, "connected", s.Connected)
+
+	return c, s
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestDelete(t *testing.T) {
+	path := socketPath(t)
+	b := listen(t, path)
+
+	c1, s1 := subscribe(t, path)
+	c2, _ := subscribe(t, path)
+
+	c1.Set("a", 1)
+	c2.Set("a", 1)
+	c2.Set("b", 2)
+
+	if err := s1.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c1.Contains("a") {
+		t.Errorf("expected a to be deleted locally at once")
+	}
+
+	eventually(t, "a deleted in the other process", func() bool { return !c2.Contains("a") })
+
+	if !c2.Contains("b") {
+		t.Errorf("expected b to be kept")
+	}
+
+	if b.Seq() != 1 {
+		t.Errorf("expected one message, got seq %d", b.Seq())
+	}
+}
+
+func TestTagAndFlush(t *testing.T) {
+	path := socketPath(t)
+	listen(t, path)
+
+	_, s1 := subscribe(t, path)
+	c2, s2 := subscribe(t, path)
+
+	c2.SetWithTags("a", 1, "users")
+	c2.SetWithTags("b", 2, "posts")
+
+	if err := s1.InvalidateTag("users"); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the users tag invalidated", func() bool { return !c2.Contains("a") && c2.Contains("b") })
+
+	if err := s1.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the flush", func() bool { return c2.Len() == 0 })
+
+	// the messages came in sequence, nothing was missed
+	if st := s2.Stats(); st.Gaps != 0 || st.Received != 2 {
+		t.Errorf("expected 2 messages without gaps, got %+v", st)
+	}
+}
+
+func TestReconnectFlushesOnNewEpoch(t *testing.T) {
+	path := socketPath(t)
+	b := listen(t, path)
+
+	c, s := subscribe(t, path)
+	c.Set("a", 1)
+
+	// the broadcaster restarts, the messages sent meanwhile are lost
+	b.Close()
+
+	eventually(t, "the disconnection", func() bool { return !s.Connected() })
+
+	if err := s.Delete("x"); err != ErrNotConnected {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+
+	listen(t, path)
+
+	eventually(t, "the reconnection", func() bool { return s.Stats().Connects == 2 })
+
+	if c.Contains("a") {
+		t.Errorf("expected the sieve to be flushed on the new epoch")
+	}
+
+	if st := s.Stats(); st.Gaps != 1 {
+		t.Errorf("expected 1 gap, got %+v", st)
+	}
+}
+
+func TestGap(t *testing.T) {
+	path := socketPath(t)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c := sieve.New[string, int](100)
+	s := Subscribe(path, c, sieve.JSONCodec[string]{})
+
+	defer s.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	w.Write(message{kind: kindHello, epoch: 7, seq: 10, payload: nil}.encode())
+	w.Flush()
+
+	eventually(t, "connected", s.Connected)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	w.Write(message{kind: kindDelete, epoch: 7, seq: 11, payload: []byte(`"a"`)}.encode())
+	w.Flush()
+
+	eventually(t, "a deleted", func() bool { return !c.Contains("a") })
+
+	if !c.Contains("b") {
+		t.Fatalf("expected b to be kept while in sequence")
+	}
+
+	// 12 is missing
+	w.Write(message{kind: kindDelete, epoch: 7, seq: 13, payload: []byte(`"z"`)}.encode())
+	w.Flush()
+
+	eventually(t, "the flush on the gap", func() bool { return !c.Contains("b") })
+
+	if st := s.Stats(); st.Gaps != 1 || st.Received != 2 {
+		t.Errorf("expected 1 gap in 2 messages, got %+v", st)
+	}
+}
+
+func TestListenInUse(t *testing.T) {
+	path := socketPath(t)
+	listen(t, path)
+
+	if _, err := Listen(path); err != ErrInUse {
+		t.Errorf("expected ErrInUse, got %v", err)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := socketPath(t)
+
+	// a file left by a broadcaster that died
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	listen(t, path)
+}
+
+func TestCloseTwice(t *testing.T) {
+	path := socketPath(t)
+	listen(t, path)
+
+	_, s := subscribe(t, path)
+
+	// the cleanup closes it once more
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Connected() {
+		t.Errorf("expected the subscriber to be disconnected")
+	}
+}
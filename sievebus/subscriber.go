@@ -0,0 +1,250 @@
+package sievebus
+
+import (
+	"bufio"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// ErrNotConnected is returned when a message cannot be sent because the broadcaster is not reachable.
+// The change was still applied to the local sieve.
+var ErrNotConnected = errors.New("sievebus: not connected")
+
+const (
+	minBackoff = 50 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// Stats are the counters of a subscriber.
+type Stats struct {
+	// Received counts the messages applied to the sieve.
+	Received uint64
+	// Gaps counts the flushes caused by a missed message or a new epoch.
+	Gaps uint64
+	// Connects counts the connections to the broadcaster, the first one included.
+	Connects uint64
+}
+
+// Subscriber applies the messages of a broadcaster to a sieve, and sends it the changes made through it.
+type Subscriber[K comparable, V any] struct {
+	path  string
+	cache *sieve.Cache[K, V]
+	keys  sieve.Codec[K]
+
+	mu   sync.Mutex
+	conn net.Conn
+	// epoch and seq are the last message seen, known is false before the first connection.
+	epoch uint64
+	seq   uint64
+	known bool
+	stats Stats
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Subscribe connects cache to the broadcaster at path, reconnecting with an exponential backoff
+// from 50ms to 5s when the connection breaks, until Close.
+func Subscribe[K comparable, V any](path string, cache *sieve.Cache[K, V], keys sieve.Codec[K]) *Subscriber[K, V] {
+	s := &Subscriber[K, V]{
+		path:      path,
+		cache:     cache,
+		keys:      keys,
+		mu:        sync.Mutex{},
+		conn:      nil,
+		epoch:     0,
+		seq:       0,
+		known:     false,
+		stats:     Stats{Received: 0, Gaps: 0, Connects: 0},
+		closed:    make(chan struct{}),
+		closeOnce: sync.Once{},
+		done:      make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *Subscriber[K, V]) run() {
+	defer close(s.done)
+
+	backoff := minBackoff
+
+	for {
+		conn, err := net.Dial("unix", s.path)
+		if err == nil {
+			backoff = minBackoff
+
+			s.serve(conn)
+
+			conn.Close()
+		}
+
+		// half of the backoff is random, so the subscribers of a restarted broadcaster do not come back together
+		wait := backoff/2 + rand.N(backoff/2)
+
+		select {
+		case <-s.closed:
+			return
+		case <-time.After(wait):
+		}
+
+		if err != nil {
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+// serve applies the messages of one connection until it breaks.
+func (s *Subscriber[K, V]) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	hello, err := readMessage(r)
+	if err != nil || hello.kind != kindHello {
+		return
+	}
+
+	s.mu.Lock()
+
+	// the messages sent while disconnected, or a broadcaster that restarted, are lost
+	if s.known && (hello.epoch != s.epoch || hello.seq != s.seq) {
+		s.gap()
+	}
+
+	s.epoch, s.seq, s.known = hello.epoch, hello.seq, true
+	s.conn = conn
+	s.stats.Connects++
+
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	// Close ran before conn was set, nobody else will close it
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			return
+		}
+
+		s.apply(m)
+	}
+}
+
+func (s *Subscriber[K, V]) apply(m message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.epoch != s.epoch || m.seq != s.seq+1 {
+		s.gap()
+	}
+
+	s.epoch, s.seq = m.epoch, m.seq
+	s.stats.Received++
+
+	switch m.kind {
+	case kindDelete:
+		// a key this process cannot decode is not one of its keys
+		if key, err := s.keys.Unmarshal(m.payload); err == nil {
+			s.cache.Delete(key)
+		}
+	case kindTag:
+		s.cache.InvalidateTag(string(m.payload))
+	case kindFlush:
+		s.cache.Flush()
+	case kindHello:
+	}
+}
+
+func (s *Subscriber[K, V]) gap() {
+	s.cache.Flush()
+	s.stats.Gaps++
+}
+
+// send writes m to the broadcaster, it relays it back to this subscriber too.
+func (s *Subscriber[K, V]) send(m message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return ErrNotConnected
+	}
+
+	_, err := s.conn.Write(m.encode())
+
+	return err
+}
+
+// Delete removes key from the sieve and from the sieves of the other subscribers.
+func (s *Subscriber[K, V]) Delete(key K) error {
+	s.cache.Delete(key)
+
+	b, err := s.keys.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	return s.send(message{kind: kindDelete, epoch: 0, seq: 0, payload: b})
+}
+
+// InvalidateTag removes the keys tagged with tag from the sieve and from the sieves of the other subscribers.
+func (s *Subscriber[K, V]) InvalidateTag(tag string) error {
+	s.cache.InvalidateTag(tag)
+
+	return s.send(message{kind: kindTag, epoch: 0, seq: 0, payload: []byte(tag)})
+}
+
+// Flush empties the sieve and the sieves of the other subscribers.
+func (s *Subscriber[K, V]) Flush() error {
+	s.cache.Flush()
+
+	return s.send(message{kind: kindFlush, epoch: 0, seq: 0, payload: nil})
+}
+
+// Connected reports whether the subscriber is connected to the broadcaster.
+func (s *Subscriber[K, V]) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.conn != nil
+}
+
+// Stats returns the counters of the subscriber.
+func (s *Subscriber[K, V]) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+// Close disconnects from the broadcaster and stops reconnecting.
+// Calling it again does nothing.
+func (s *Subscriber[K, V]) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+
+	<-s.done
+
+	return nil
+}
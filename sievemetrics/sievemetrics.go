@@ -0,0 +1,228 @@
+// Package sievemetrics exposes the stats of sieve caches in the OpenMetrics text format,
+// without any client library.
+package sievemetrics
+
+import (
+	"bufio"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// ContentType is the content type of the exposition written by Registry.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// ErrDuplicate is returned when registering a name twice.
+var ErrDuplicate = errors.New("sievemetrics: cache already registered")
+
+// Source is anything with sieve stats, every *sieve.Cache is one.
+type Source interface {
+	Stats() sieve.Stats
+}
+
+// Registry holds the caches to expose, each with its own name.
+type Registry struct {
+	mu      sync.Mutex
+	sources map[string]Source
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:      sync.Mutex{},
+		sources: make(map[string]Source),
+	}
+}
+
+// Register adds the cache under name, the value of the cache label.
+func (r *Registry) Register(name string, c Source) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, name)
+	}
+
+	r.sources[name] = c
+
+	return nil
+}
+
+// Unregister removes the cache registered under name.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sources, name)
+}
+
+// named is the stats of one cache, with its name.
+type named struct {
+	name  string
+	stats sieve.Stats
+}
+
+// metric is a metric family, write writes its samples for one cache.
+type metric struct {
+	name  string
+	typ   string
+	help  string
+	write func(w *bufio.Writer, name string, labels string, st sieve.Stats)
+}
+
+var metrics = []metric{
+	counter("sieve_hits", "Number of lookups that found the key.", func(st sieve.Stats) uint64 { return st.Hits }),
+	counter("sieve_misses", "Number of lookups that did not find the key.", func(st sieve.Stats) uint64 { return st.Misses }),
+	counter("sieve_negative_hits", "Number of lookups that found a key known not to exist.", func(st sieve.Stats) uint64 { return st.NegativeHits }),
+	{
+		name: "sieve_evictions",
+		typ:  "counter",
+		help: "Number of keys removed by the cache, by reason.",
+		write: func(w *bufio.Writer, name string, labels string, st sieve.Stats) {
+			writeSample(w, name+"_total", labels+`,reason="capacity"`, strconv.FormatUint(st.Evictions, 10))
+			writeSample(w, name+"_total", labels+`,reason="expired"`, strconv.FormatUint(st.Expirations, 10))
+		},
+	},
+	gauge("sieve_size", "Number of keys in the cache.", func(st sieve.Stats) int64 { return int64(st.Len) }),
+	gauge("sieve_capacity", "Maximum number of keys in the cache.", func(st sieve.Stats) int64 { return int64(st.Capacity) }),
+	gauge("sieve_pinned", "Number of pinned keys.", func(st sieve.Stats) int64 { return int64(st.Pinned) }),
+	{
+		name: "sieve_load_duration_seconds",
+		typ:  "histogram",
+		help: "Latency of the loads from the backend.",
+		write: func(w *bufio.Writer, name string, labels string, st sieve.Stats) {
+			writeHistogram(w, name, labels, st.LoadLatency, func(d time.Duration) float64 { return d.Seconds() })
+		},
+	},
+	{
+		name: "sieve_sweep_length",
+		typ:  "histogram",
+		help: "Number of nodes the hand moved over to find each victim.",
+		write: func(w *bufio.Writer, name string, labels string, st sieve.Stats) {
+			writeHistogram(w, name, labels, st.SweepLength, func(n int64) float64 { return float64(n) })
+		},
+	},
+}
+
+func counter(name, help string, value func(sieve.Stats) uint64) metric {
+	return metric{
+		name: name,
+		typ:  "counter",
+		help: help,
+		write: func(w *bufio.Writer, name string, labels string, st sieve.Stats) {
+			writeSample(w, name+"_total", labels, strconv.FormatUint(value(st), 10))
+		},
+	}
+}
+
+func gauge(name, help string, value func(sieve.Stats) int64) metric {
+	return metric{
+		name: name,
+		typ:  "gauge",
+		help: help,
+		write: func(w *bufio.Writer, name string, labels string, st sieve.Stats) {
+			writeSample(w, name, labels, strconv.FormatInt(value(st), 10))
+		},
+	}
+}
+
+// WriteTo writes the metrics of all the caches, sorted by name, ending with the # EOF marker.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+
+	caches := make([]named, 0, len(r.sources))
+	for name, src := range r.sources {
+		caches = append(caches, named{name: name, stats: src.Stats()})
+	}
+
+	r.mu.Unlock()
+
+	slices.SortFunc(caches, func(a, b named) int { return strings.Compare(a.name, b.name) })
+
+	cw := &countingWriter{w: w, n: 0}
+	bw := bufio.NewWriter(cw)
+
+	for _, m := range metrics {
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.typ)
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+
+		for _, c := range caches {
+			m.write(bw, m.name, `cache="`+escape(c.name)+`"`, c.stats)
+		}
+	}
+
+	bw.WriteString("# EOF\n")
+
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+// ServeHTTP writes the metrics, so that the registry can be mounted on a mux, usually at /metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+
+	// the client went away, there is nobody to tell
+	_, _ = r.WriteTo(w)
+}
+
+func writeSample(w *bufio.Writer, name, labels, value string) {
+	w.WriteString(name)
+	w.WriteString("{")
+	w.WriteString(labels)
+	w.WriteString("} ")
+	w.WriteString(value)
+	w.WriteString("\n")
+}
+
+// writeHistogram writes the cumulative buckets of h, with the values converted by unit.
+func writeHistogram[T ~int64](w *bufio.Writer, name, labels string, h sieve.Histogram[T], unit func(T) float64) {
+	var cumulative uint64
+
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+
+		le := strconv.FormatFloat(unit(bound), 'g', -1, 64)
+		writeSample(w, name+"_bucket", labels+`,le="`+le+`"`, strconv.FormatUint(cumulative, 10))
+	}
+
+	writeSample(w, name+"_bucket", labels+`,le="+Inf"`, strconv.FormatUint(h.Count, 10))
+	writeSample(w, name+"_sum", labels, strconv.FormatFloat(unit(h.Sum), 'g', -1, 64))
+	writeSample(w, name+"_count", labels, strconv.FormatUint(h.Count, 10))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes a label value as OpenMetrics requires.
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err //nolint: wrapcheck // it is the writer given to WriteTo
+}
+
+// Publish exposes the stats of the cache as the expvar variable called name, so they show up in /debug/vars.
+// Like expvar.Publish, it panics if the name is already used.
+func Publish(name string, c Source) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return c.Stats()
+	}))
+}
@@ -0,0 +1,143 @@
+package sievemetrics_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/guerinoni/sieve"
+	"github.com/guerinoni/sieve/sievemetrics"
+)
+
+func TestWriteTo(t *testing.T) {
+	users := sieve.New[int, string](2)
+	users.Set(1, "one")
+	users.Set(2, "two")
+	users.Get(1)
+	users.Get(3)
+	users.Set(3, "three")
+
+	loader := sieve.NewLoadingCache(sieve.New[int, string](10), sieve.NewMemoryStore[int, string](), sieve.ReadThrough)
+	loader.Get(context.Background(), 1)
+
+	r := sievemetrics.NewRegistry()
+
+	if err := r.Register("users", users); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Register(`we"ird`, loader.Cache()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Register("users", users); !errors.Is(err, sievemetrics.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+
+	var b strings.Builder
+
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := b.String()
+
+	if int(n) != len(out) {
+		t.Errorf("expected %d bytes written, got %d", len(out), n)
+	}
+
+	for _, line := range []string{
+		"# TYPE sieve_hits counter",
+		`sieve_hits_total{cache="users"} 1`,
+		`sieve_misses_total{cache="users"} 1`,
+		`sieve_evictions_total{cache="users",reason="capacity"} 1`,
+		`sieve_evictions_total{cache="users",reason="expired"} 0`,
+		`sieve_size{cache="users"} 2`,
+		`sieve_capacity{cache="users"} 2`,
+		"# TYPE sieve_load_duration_seconds histogram",
+		`sieve_load_duration_seconds_bucket{cache="users",le="+Inf"} 0`,
+		`sieve_load_duration_seconds_count{cache="we\"ird"} 1`,
+		`sieve_load_duration_seconds_bucket{cache="we\"ird",le="+Inf"} 1`,
+		`sieve_load_duration_seconds_bucket{cache="we\"ird",le="0.0001"}`,
+		"# TYPE sieve_sweep_length histogram",
+		`sieve_sweep_length_bucket{cache="users",le="1"} 1`,
+		`sieve_sweep_length_sum{cache="users"} 1`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected line %q in:\n%s", line, out)
+		}
+	}
+
+	if !strings.HasSuffix(out, "\n# EOF\n") {
+		t.Errorf("expected the exposition to end with # EOF")
+	}
+
+	// the caches are sorted by name inside each family
+	if strings.Index(out, `sieve_hits_total{cache="users"}`) > strings.Index(out, `sieve_hits_total{cache="we\"ird"}`) {
+		t.Errorf("expected the caches to be sorted by name")
+	}
+
+	r.Unregister("users")
+
+	b.Reset()
+	r.WriteTo(&b)
+
+	if strings.Contains(b.String(), `cache="users"`) {
+		t.Errorf("expected users to be gone")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := sievemetrics.NewRegistry()
+	r.Register("users", sieve.New[int, string](2))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != sievemetrics.ContentType {
+		t.Errorf("expected content type %s, got %s", sievemetrics.ContentType, ct)
+	}
+
+	body, _ := io.ReadAll(res.Body)
+
+	if !strings.Contains(string(body), `sieve_capacity{cache="users"} 2`) {
+		t.Errorf("expected the capacity in:\n%s", body)
+	}
+}
+
+var publishRuns atomic.Int64
+
+func TestPublish(t *testing.T) {
+	c := sieve.New[int, string](2)
+	c.Set(1, "one")
+	c.Get(1)
+
+	// expvar names can't be reused, and -count runs the test again in the same process
+	name := "sieve_test_cache_" + strconv.FormatInt(publishRuns.Add(1), 10)
+
+	sievemetrics.Publish(name, c)
+
+	var st sieve.Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &st); err != nil {
+		t.Fatal(err)
+	}
+
+	if st.Hits != 1 || st.Len != 1 || st.Capacity != 2 {
+		t.Errorf("expected the stats of the cache, got %+v", st)
+	}
+}
@@ -0,0 +1,179 @@
+// Package sievetest helps testing code built on sieve: a clock to move the time forward without sleeping,
+// a recorder of the evictions and assertions on the content of a sieve.
+package sievetest
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+)
+
+// Clock is a time source that only moves when told to, to pass to Cache.WithClock.
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewClock returns a clock stopped at start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{mu: sync.Mutex{}, t: start}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.t
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = c.t.Add(d)
+}
+
+// Set moves the clock to t, even backwards.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = t
+}
+
+// Event is a key that left a sieve, as seen by Recorder.
+type Event[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Reason sieve.EvictReason
+}
+
+// Recorder records the keys that leave a sieve, pass its Record method to Cache.OnEvict.
+type Recorder[K comparable, V any] struct {
+	mu     sync.Mutex
+	events []Event[K, V]
+}
+
+// NewRecorder returns an empty recorder.
+func NewRecorder[K comparable, V any]() *Recorder[K, V] {
+	return &Recorder[K, V]{mu: sync.Mutex{}, events: nil}
+}
+
+// Record records one event, it has the signature OnEvict wants.
+func (r *Recorder[K, V]) Record(key K, value V, reason sieve.EvictReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, Event[K, V]{Key: key, Value: value, Reason: reason})
+}
+
+// Events returns the recorded events, in order.
+func (r *Recorder[K, V]) Events() []Event[K, V] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.events)
+}
+
+// Evicted returns the keys removed by the hand or expired, in order, leaving out the deleted ones.
+func (r *Recorder[K, V]) Evicted() []K {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []K
+
+	for _, e := range r.events {
+		if e.Reason != sieve.Deleted {
+			keys = append(keys, e.Key)
+		}
+	}
+
+	return keys
+}
+
+// Reset forgets the recorded events.
+func (r *Recorder[K, V]) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = nil
+}
+
+// AssertContains fails the test if any of the keys is not in the sieve.
+// It uses Peek, so the visited bits are left as they are.
+func AssertContains[K comparable, V any](t testing.TB, c *sieve.Cache[K, V], keys ...K) {
+	t.Helper()
+
+	for _, k := range keys {
+		if _, ok := c.Peek(k); !ok {
+			t.Errorf("expected key %v in %s", k, c)
+		}
+	}
+}
+
+// AssertNotContains fails the test if any of the keys is in the sieve.
+// It uses Peek, so the visited bits are left as they are.
+func AssertNotContains[K comparable, V any](t testing.TB, c *sieve.Cache[K, V], keys ...K) {
+	t.Helper()
+
+	for _, k := range keys {
+		if _, ok := c.Peek(k); ok {
+			t.Errorf("expected key %v to not be in %s", k, c)
+		}
+	}
+}
+
+// AssertEvictionOrder fails the test if the keys evicted or expired since the recorder started,
+// or since its last Reset, are not exactly keys in the same order.
+func AssertEvictionOrder[K comparable, V any](t testing.TB, r *Recorder[K, V], keys ...K) {
+	t.Helper()
+
+	if got := r.Evicted(); !slices.Equal(got, keys) {
+		t.Errorf("expected the keys to be evicted in the order %v, got %v", keys, got)
+	}
+}
+
+// AssertVisited fails the test if the visited bits of the keys are not the expected ones.
+func AssertVisited[K comparable, V any](t testing.TB, c *sieve.Cache[K, V], visited map[K]bool) {
+	t.Helper()
+
+	entries := make(map[K]sieve.Entry[K, V])
+	for _, e := range c.Entries() {
+		entries[e.Key] = e
+	}
+
+	for k, expected := range visited {
+		e, ok := entries[k]
+		if !ok {
+			t.Errorf("expected key %v in %s", k, c)
+
+			continue
+		}
+
+		if e.Visited != expected {
+			t.Errorf("expected key %v to have visited %v, got %v", k, expected, e.Visited)
+		}
+	}
+}
+
+// AssertHand fails the test if the hand does not point to key.
+func AssertHand[K comparable, V any](t testing.TB, c *sieve.Cache[K, V], key K) {
+	t.Helper()
+
+	for _, e := range c.Entries() {
+		if e.Hand {
+			if e.Key != key {
+				t.Errorf("expected the hand on key %v, got it on %v", key, e.Key)
+			}
+
+			return
+		}
+	}
+
+	t.Errorf("expected the hand on key %v, got an empty sieve", key)
+}
@@ -0,0 +1,109 @@
+package sievetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/guerinoni/sieve"
+	"github.com/guerinoni/sieve/sievetest"
+)
+
+// fakeT counts the failures instead of failing the test.
+type fakeT struct {
+	testing.TB
+
+	failures int
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(string, ...any) {
+	f.failures++
+}
+
+func TestClockTTL(t *testing.T) {
+	clock := sievetest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	rec := sievetest.NewRecorder[int, string]()
+
+	c := sieve.New[int, string](4).WithTTL(time.Minute).WithClock(clock.Now).OnEvict(rec.Record)
+
+	c.Set(1, "one")
+	c.SetWithTTL(2, "two", 10*time.Second)
+
+	clock.Advance(11 * time.Second)
+
+	sievetest.AssertContains(t, c, 1)
+	sievetest.AssertNotContains(t, c, 2)
+
+	clock.Advance(time.Minute)
+
+	sievetest.AssertNotContains(t, c, 1)
+	sievetest.AssertEvictionOrder(t, rec, 2, 1)
+
+	if events := rec.Events(); events[0].Reason != sieve.Expired || events[1].Value != "one" {
+		t.Errorf("expected two expirations, got %v", events)
+	}
+}
+
+func TestEvictionOrder(t *testing.T) {
+	rec := sievetest.NewRecorder[int, string]()
+	c := sieve.New[int, string](3).OnEvict(rec.Record)
+
+	c.Set(1, "one")
+	c.Set(2, "two")
+	c.Set(3, "three")
+	c.Get(1)
+
+	sievetest.AssertVisited(t, c, map[int]bool{1: true, 2: false, 3: false})
+	sievetest.AssertHand(t, c, 1)
+
+	c.Set(4, "four")
+	c.Set(5, "five")
+
+	sievetest.AssertEvictionOrder(t, rec, 2, 3)
+	sievetest.AssertVisited(t, c, map[int]bool{1: false})
+
+	// deletes are recorded but they are not evictions
+	c.Delete(4)
+
+	if events := rec.Events(); len(events) != 3 || events[2].Reason != sieve.Deleted {
+		t.Errorf("expected the delete to be recorded, got %v", events)
+	}
+
+	sievetest.AssertEvictionOrder(t, rec, 2, 3)
+
+	rec.Reset()
+	sievetest.AssertEvictionOrder[int, string](t, rec)
+}
+
+func TestAssertionsFail(t *testing.T) {
+	c := sieve.New[int, string](2)
+	c.Set(1, "one")
+
+	rec := sievetest.NewRecorder[int, string]()
+
+	f := &fakeT{TB: t, failures: 0}
+
+	sievetest.AssertContains(f, c, 2)
+	sievetest.AssertNotContains(f, c, 1)
+	sievetest.AssertEvictionOrder(f, rec, 1)
+	sievetest.AssertVisited(f, c, map[int]bool{1: true, 3: false})
+	sievetest.AssertHand(f, c, 3)
+	sievetest.AssertHand(f, sieve.New[int, string](2), 3)
+
+	if f.failures != 7 {
+		t.Errorf("expected 7 failures, got %d", f.failures)
+	}
+}
+
+func TestClockSet(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := sievetest.NewClock(start)
+
+	clock.Advance(time.Hour)
+	clock.Set(start)
+
+	if !clock.Now().Equal(start) {
+		t.Errorf("expected the clock back at %v, got %v", start, clock.Now())
+	}
+}
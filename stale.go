@@ -0,0 +1,81 @@
+package sieve
+
+import (
+	"log/slog"
+	"time"
+)
+
+// WithStaleWhileRevalidate is a builder function used to serve stale values while they are refreshed.
+// The deadlines count from the last write of a key, not from its last read:
+// before soft the value is fresh, between soft and hard Get returns the stale value
+// and refreshes it in the background with refresh, once per key at a time,
+// after hard the key is expired like with WithTTL.
+// A failed refresh keeps the stale value until the next Get tries again.
+// Refreshes run on their own goroutine, so the sieve must be the thread-safe one returned by New.
+// If soft is not shorter than hard, it panics, so for values coming from configuration
+// use NewWithOptions with the WithStaleWhileRevalidate option, which returns a ConfigError instead.
+func (s *Cache[K, V]) WithStaleWhileRevalidate(soft, hard time.Duration, refresh func(key K) (V, error)) *Cache[K, V] {
+	if soft <= 0 || soft >= hard {
+		panic(softTTLError().Error())
+	}
+
+	s.softTTL = soft
+	s.hardTTL = hard
+	s.refresh = refresh
+
+	return s
+}
+
+// WithRefreshAhead is a builder function used to refresh hot keys before they become stale.
+// A key read again, while already marked visited, within window of its soft deadline is refreshed
+// in the background, so popular keys are never served stale.
+// It needs WithStaleWhileRevalidate.
+func (s *Cache[K, V]) WithRefreshAhead(window time.Duration) *Cache[K, V] {
+	s.refreshAhead = window
+
+	return s
+}
+
+// shouldRefresh reports whether a Get of n must trigger a refresh.
+func (s *Cache[K, V]) shouldRefresh(n *node[K, V], atNow time.Time) bool {
+	age := atNow.Sub(n.written())
+
+	if age > s.softTTL {
+		return true
+	}
+
+	// only hot keys, the visited bit is still the one from before this Get
+	return n.visited && s.refreshAhead > 0 && age > s.softTTL-s.refreshAhead
+}
+
+// refreshNode loads a new value for n, written is the write time the refresh started from.
+func (s *Cache[K, V]) refreshNode(n *node[K, V], written time.Time) {
+	start := time.Now()
+	v, err := s.refresh(n.key)
+	s.stats.loads.observe(time.Since(start))
+
+	if err != nil {
+		s.logger.log("sieve: refresh failed", slog.Any("key", n.key), slog.Any("error", err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the refresh marked n, so its extra fields are there
+	e := n.extra
+	e.refreshing = false
+
+	// the node left the sieve, or a Set replaced the value meanwhile: that one is newer
+	if cur, ok := s.keyspace(e.ns)[n.key]; !ok || cur != n || !e.written.Equal(written) {
+		return
+	}
+
+	if err != nil {
+		return
+	}
+
+	n.value = v
+	e.written = s.now()
+
+	s.logSet(n)
+}
@@ -0,0 +1,199 @@
+package sieve
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// refresher answers refreshes with the current call count, after the test lets it go.
+type refresher struct {
+	calls   atomic.Int32
+	release chan struct{}
+	fail    atomic.Bool
+}
+
+func newRefresher() *refresher {
+	return &refresher{release: make(chan struct{})}
+}
+
+func (r *refresher) refresh(int) (string, error) {
+	n := r.calls.Add(1)
+
+	<-r.release
+
+	if r.fail.Load() {
+		return "", errors.New("backend is down")
+	}
+
+	return "refreshed " + string(rune('0'+n)), nil
+}
+
+// waitValue waits until the refreshed value is in the sieve.
+func waitValue(t *testing.T, s *Cache[int, string], key int, expected string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		if v, _ := s.Peek(key); v == expected {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	v, _ := s.Peek(key)
+	t.Fatalf("expected value '%s', got '%s'", expected, v)
+}
+
+// waitRefreshDone waits until no refresh of the key is running.
+func waitRefreshDone(s *Cache[int, string], key int) {
+	for {
+		s.mu.Lock()
+		n, ok := s.m[key]
+		running := ok && n.refreshing()
+		s.mu.Unlock()
+
+		if !running {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	r := newRefresher()
+	s := New[int, string](4).WithStaleWhileRevalidate(2*time.Second, 5*time.Second, r.refresh)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(7, "original")
+
+	if v, _ := s.Get(7); v != "original" || r.calls.Load() != 0 {
+		t.Errorf("expected a fresh value without refresh, got '%s'", v)
+	}
+
+	sec = 4
+
+	// stale: served as is, one refresh for all callers
+	for range 3 {
+		if v, ok := s.Get(7); !ok || v != "original" {
+			t.Errorf("expected the stale value, got '%s'", v)
+		}
+	}
+
+	close(r.release)
+	waitValue(t, s, 7, "refreshed 1")
+
+	if r.calls.Load() != 1 {
+		t.Errorf("expected 1 refresh, got %d", r.calls.Load())
+	}
+
+	// the refresh restarted both deadlines from sec 4
+	sec = 8
+
+	if v, ok := s.Get(7); !ok || v != "refreshed 1" {
+		t.Errorf("expected the refreshed value, got '%s'", v)
+	}
+
+	waitValue(t, s, 7, "refreshed 2")
+
+	// past the hard deadline the key is gone
+	sec = 20
+
+	if _, ok := s.Get(7); ok {
+		t.Errorf("expected key 7 to be expired")
+	}
+}
+
+func TestStaleWhileRevalidateFailure(t *testing.T) {
+	r := newRefresher()
+	r.fail.Store(true)
+	close(r.release)
+
+	s := New[int, string](4).WithStaleWhileRevalidate(2*time.Second, 5*time.Second, r.refresh)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(7, "original")
+
+	sec = 4
+
+	s.Get(7)
+	waitRefreshDone(s, 7)
+
+	// the stale value survives and the next Get tries again
+	if v, ok := s.Get(7); !ok || v != "original" {
+		t.Errorf("expected the stale value, got '%s'", v)
+	}
+
+	waitRefreshDone(s, 7)
+
+	if r.calls.Load() != 2 {
+		t.Errorf("expected 2 refreshes, got %d", r.calls.Load())
+	}
+}
+
+func TestStaleWhileRevalidateSetWins(t *testing.T) {
+	r := newRefresher()
+	s := New[int, string](4).WithStaleWhileRevalidate(2*time.Second, 5*time.Second, r.refresh)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(7, "original")
+
+	sec = 4
+
+	s.Get(7)
+	s.Set(7, "newer")
+
+	close(r.release)
+	waitRefreshDone(s, 7)
+
+	if v, _ := s.Peek(7); v != "newer" {
+		t.Errorf("expected the value from Set, got '%s'", v)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	r := newRefresher()
+	close(r.release)
+
+	s := New[int, string](4).WithStaleWhileRevalidate(10*time.Second, 20*time.Second, r.refresh).WithRefreshAhead(3 * time.Second)
+
+	sec := 1
+	now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, sec, 0, time.UTC) }
+
+	s.Set(7, "hot")
+	s.Set(8, "cold")
+	s.Get(7)
+
+	sec = 9
+
+	// 8 is not visited, so it is not hot enough
+	s.Get(8)
+	waitRefreshDone(s, 8)
+
+	if r.calls.Load() != 0 {
+		t.Errorf("expected no refresh of a cold key, got %d", r.calls.Load())
+	}
+
+	s.Get(7)
+	waitValue(t, s, 7, "refreshed 1")
+}
+
+func TestStaleWhileRevalidatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic but got none")
+		}
+	}()
+
+	New[int, string](4).WithStaleWhileRevalidate(2*time.Second, time.Second, newRefresher().refresh)
+}
@@ -0,0 +1,93 @@
+package sieve
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned when a key does not exist in the backing store.
+var ErrNotFound = errors.New("sieve: key not found")
+
+// Store is the backing storage behind a LoadingCache, usually a database.
+type Store[K comparable, V any] interface {
+	// Load returns the value of the key, or ErrNotFound if it does not exist.
+	Load(ctx context.Context, key K) (V, error)
+	// LoadMany returns the values of the keys, the keys that do not exist are left out of the map.
+	LoadMany(ctx context.Context, keys []K) (map[K]V, error)
+	// Save inserts or updates the value of the key.
+	Save(ctx context.Context, key K, value V) error
+	// Delete removes the key, it is not an error if the key does not exist.
+	Delete(ctx context.Context, key K) error
+}
+
+// MemoryStore is a Store that keeps everything in a map, useful in tests.
+type MemoryStore[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]V
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore[K comparable, V any]() *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{
+		mu: sync.Mutex{},
+		m:  make(map[K]V),
+	}
+}
+
+// Load implements Store.
+func (s *MemoryStore[K, V]) Load(_ context.Context, key K) (V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.m[key]
+	if !ok {
+		return v, ErrNotFound
+	}
+
+	return v, nil
+}
+
+// LoadMany implements Store.
+func (s *MemoryStore[K, V]) LoadMany(_ context.Context, keys []K) (map[K]V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make(map[K]V, len(keys))
+
+	for _, k := range keys {
+		if v, ok := s.m[k]; ok {
+			values[k] = v
+		}
+	}
+
+	return values, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore[K, V]) Save(_ context.Context, key K, value V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m[key] = value
+
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore[K, V]) Delete(_ context.Context, key K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.m, key)
+
+	return nil
+}
+
+// Len returns the number of keys in the store.
+func (s *MemoryStore[K, V]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.m)
+}
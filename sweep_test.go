@@ -0,0 +1,75 @@
+package sieve
+
+import "testing"
+
+func TestSweepLength(t *testing.T) {
+	s := New[int, int](100)
+
+	for i := range 100 {
+		s.Set(i, i)
+		s.Get(i)
+	}
+
+	// the hand clears every node and wraps around to the tail
+	s.Set(100, 100)
+
+	sl := s.Stats().SweepLength
+	if sl.Count != 1 || sl.Sum != 100 {
+		t.Errorf("expected one sweep of 100 nodes, got %+v", sl)
+	}
+
+	// 100 is in the bucket up to 256
+	if sl.Counts[5] != 1 {
+		t.Errorf("expected the sweep in the bucket up to 256, got %v", sl.Counts)
+	}
+}
+
+func TestMaxSweep(t *testing.T) {
+	s := New[int, int](100).WithMaxSweep(8)
+
+	for i := range 100 {
+		s.Set(i, i)
+		s.Get(i)
+	}
+
+	s.Set(100, 100)
+
+	// the hand gave up after 8 nodes and evicted the visited 8
+	if s.Contains(8) || !s.Contains(0) || !s.Contains(9) {
+		t.Errorf("expected key 8 to be evicted")
+	}
+
+	if sl := s.Stats().SweepLength; sl.Sum != 8 {
+		t.Errorf("expected a sweep of 8 nodes, got %+v", sl)
+	}
+
+	// the nodes swept over lost their visited bit
+	for i := range 8 {
+		if s.m[i].visited {
+			t.Errorf("expected key %d to not be visited", i)
+		}
+	}
+
+	if !s.m[9].visited {
+		t.Errorf("expected key 9 to still be visited")
+	}
+}
+
+func TestMaxSweepSkipsPinned(t *testing.T) {
+	s := New[int, int](4).WithMaxSweep(1)
+
+	for i := range 4 {
+		s.Set(i, i)
+		s.Get(i)
+	}
+
+	s.Pin(1)
+	s.Pin(2)
+
+	s.Set(4, 4)
+
+	// 1 and 2 are pinned, the first node past the bound that can go is 3
+	if s.Contains(3) || !s.Contains(1) || !s.Contains(2) {
+		t.Errorf("expected key 3 to be evicted, got %s", s)
+	}
+}
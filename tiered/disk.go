@@ -0,0 +1,457 @@
+package tiered
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/guerinoni/sieve"
+)
+
+// DefaultSegmentSize is the size after which a new segment is started.
+const DefaultSegmentSize = 64 << 20
+
+// location is where the record of a live key is.
+type location struct {
+	segment *segment
+	offset  int64
+	size    int64
+}
+
+// Disk is a SIEVE cache of byte values stored in log-structured segment files, with the index in memory.
+//
+// Every write appends a record to the last segment, a put or the tombstone of a deleted or evicted key,
+// and the index is a sieve from the keys to their records. Open rebuilds the index by replaying the segments,
+// dropping a torn record at the end of the last one, so a crash loses at most the writes not yet synced.
+// The visited bits are not on disk, so after Open the keys start unvisited.
+// The segments are removed from the oldest, copying its live records forward once half of the bytes are dead,
+// so that a record is never removed before the older ones it supersedes.
+type Disk struct {
+	dir         string
+	segmentSize int64
+
+	index *sieve.Cache[string, *location]
+
+	// segments go from the oldest to the one written
+	segments []*segment
+
+	// recovering is set while Open rebuilds the index, evicted collects the keys evicted meanwhile
+	recovering bool
+	evicted    []string
+
+	// err is the first error of the tombstones written by the evictions
+	err error
+
+	mu sync.Mutex
+}
+
+// Open opens the segments in dir, creating it if needed, and rebuilds the index, keeping at most capacity keys.
+func Open(dir string, capacity int32) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	d := &Disk{
+		dir:         dir,
+		segmentSize: DefaultSegmentSize,
+		index:       nil,
+		segments:    nil,
+		recovering:  true,
+		evicted:     nil,
+		err:         nil,
+		mu:          sync.Mutex{},
+	}
+
+	d.index = sieve.NewSingleThread[string, *location](capacity).OnEvict(d.onEvict)
+
+	if err := d.recover(); err != nil {
+		d.close()
+
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// WithSegmentSize is a builder function used to change the size after which a new segment is started.
+func (d *Disk) WithSegmentSize(size int64) *Disk {
+	d.segmentSize = size
+
+	return d
+}
+
+// replayed is the last put of a key found by the replay, loc is nil once a tombstone follows it.
+type replayed struct {
+	key string
+	loc *location
+}
+
+// recovery holds the keys while Open replays the segments. They are not in the index yet:
+// the visited bits are not on disk, so the index would evict other keys than the logged tombstones say.
+type recovery struct {
+	keys map[string]*replayed
+	// order has the keys in the order of their first put after their last tombstone, as the index inserted them.
+	order []*replayed
+}
+
+// recover replays the segments from the oldest, the last one is truncated after its last valid record,
+// then inserts the keys in the index.
+func (d *Disk) recover() error {
+	ids, err := segmentIDs(d.dir)
+	if err != nil {
+		return err
+	}
+
+	r := &recovery{keys: make(map[string]*replayed), order: nil}
+
+	for i, id := range ids {
+		seg, err := openSegment(d.dir, id, 0)
+		if err != nil {
+			return err
+		}
+
+		d.segments = append(d.segments, seg)
+
+		if err := d.replay(seg, i == len(ids)-1, r); err != nil {
+			return err
+		}
+	}
+
+	// only a capacity smaller than before evicts here
+	for _, k := range r.order {
+		if k.loc != nil {
+			d.index.Set(k.key, k.loc)
+		}
+	}
+
+	if len(d.segments) == 0 {
+		seg, err := d.create(1)
+		if err != nil {
+			return err
+		}
+
+		d.segments = append(d.segments, seg)
+	}
+
+	d.recovering = false
+
+	// a smaller capacity than before evicted some keys, they must not come back on the next replay
+	for _, key := range d.evicted {
+		if _, err := d.append(kindDelete, key, nil); err != nil {
+			return err
+		}
+	}
+
+	d.evicted = nil
+
+	return d.compact()
+}
+
+// replay applies the records of seg to r. Only the last segment can end with a torn record,
+// a bad record in an older one would drop the tombstones after it.
+func (d *Disk) replay(seg *segment, last bool, r *recovery) error {
+	br := bufio.NewReader(io.NewSectionReader(seg.f, 0, math.MaxInt64))
+
+	for {
+		rec, size, err := readRecord(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			// a crash in the middle of a write, the rest was never acknowledged
+			if last {
+				return seg.f.Truncate(seg.size)
+			}
+
+			return fmt.Errorf("tiered: segment %s at %d: %w", segmentName(seg.id), seg.size, err)
+		}
+
+		loc := &location{segment: seg, offset: seg.size, size: size}
+		seg.size += size
+
+		k, ok := r.keys[rec.key]
+		if ok && k.loc != nil {
+			k.loc.segment.live -= k.loc.size
+		}
+
+		switch rec.kind {
+		case kindPut:
+			if !ok {
+				k = &replayed{key: rec.key, loc: nil}
+				r.keys[rec.key] = k
+				r.order = append(r.order, k)
+			}
+
+			k.loc = loc
+			seg.live += size
+		case kindDelete:
+			if ok {
+				k.loc = nil
+				delete(r.keys, rec.key)
+			}
+		}
+	}
+}
+
+// create starts a new segment, syncing the directory so that the file survives a crash.
+func (d *Disk) create(id uint64) (*segment, error) {
+	seg, err := openSegment(d.dir, id, os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, err
+	}
+
+	return seg, d.syncDir()
+}
+
+// syncDir makes the files created or removed in the directory survive a crash.
+func (d *Disk) syncDir() error {
+	dir, err := os.Open(d.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+// onEvict frees the record of a key leaving the index, and records the eviction.
+func (d *Disk) onEvict(key string, loc *location, reason sieve.EvictReason) {
+	loc.segment.live -= loc.size
+
+	if reason != sieve.Evicted {
+		return
+	}
+
+	if d.recovering {
+		d.evicted = append(d.evicted, key)
+
+		return
+	}
+
+	if _, err := d.append(kindDelete, key, nil); err != nil && d.err == nil {
+		d.err = err
+	}
+}
+
+// put points the index to the record of key, freeing the one it replaces.
+func (d *Disk) put(key string, loc *location) {
+	if old, ok := d.index.Peek(key); ok {
+		old.segment.live -= old.size
+	}
+
+	loc.segment.live += loc.size
+
+	d.index.Set(key, loc)
+}
+
+// append writes a record at the end of the last segment, starting a new one when it is full.
+func (d *Disk) append(kind byte, key string, value []byte) (*location, error) {
+	buf := encode(kind, key, value)
+	seg := d.segments[len(d.segments)-1]
+
+	if seg.size > 0 && seg.size+int64(len(buf)) > d.segmentSize {
+		if err := seg.f.Sync(); err != nil {
+			return nil, err
+		}
+
+		next, err := d.create(seg.id + 1)
+		if next != nil {
+			d.segments = append(d.segments, next)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		seg = next
+	}
+
+	// a failed write leaves garbage after size, the next one overwrites it
+	if _, err := seg.f.WriteAt(buf, seg.size); err != nil {
+		return nil, err
+	}
+
+	loc := &location{segment: seg, offset: seg.size, size: int64(len(buf))}
+	seg.size += loc.size
+
+	return loc, nil
+}
+
+// compact removes the oldest segments while they have no live record or half of the bytes are dead.
+func (d *Disk) compact() error {
+	for len(d.segments) > 1 {
+		oldest := d.segments[0]
+
+		if oldest.live > 0 {
+			var size, live int64
+
+			for _, seg := range d.segments {
+				size += seg.size
+				live += seg.live
+			}
+
+			if dead := size - live; dead <= live || dead <= d.segmentSize {
+				return nil
+			}
+
+			if err := d.moveLive(oldest); err != nil {
+				return err
+			}
+		}
+
+		// the records copied from the oldest segment, and the ones superseding it, must be on the disk before it is gone
+		if err := d.segments[len(d.segments)-1].f.Sync(); err != nil {
+			return err
+		}
+
+		oldest.f.Close()
+
+		if err := os.Remove(oldest.f.Name()); err != nil {
+			return err
+		}
+
+		d.segments = d.segments[1:]
+
+		if err := d.syncDir(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveLive copies the records of seg that the index points to at the end of the last segment.
+func (d *Disk) moveLive(seg *segment) error {
+	r := bufio.NewReader(io.NewSectionReader(seg.f, 0, seg.size))
+
+	for offset := int64(0); offset < seg.size; {
+		rec, size, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+
+		if loc, ok := d.index.Peek(rec.key); ok && rec.kind == kindPut && loc.segment == seg && loc.offset == offset {
+			moved, err := d.append(kindPut, rec.key, rec.value)
+			if err != nil {
+				return err
+			}
+
+			// the index keeps the same location, so the visited bit is left as it is
+			seg.live -= size
+			moved.segment.live += size
+			*loc = *moved
+		}
+
+		offset += size
+	}
+
+	return nil
+}
+
+// Get returns the value of key read from its segment, marking the key as visited.
+func (d *Disk) Get(key string) ([]byte, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	loc, ok := d.index.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	buf := make([]byte, loc.size)
+	if _, err := loc.segment.f.ReadAt(buf, loc.offset); err != nil {
+		return nil, false, err
+	}
+
+	rec, err := decode(buf)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return rec.value, true, nil
+}
+
+// Set appends the value of key, evicting with SIEVE when the index is full.
+func (d *Disk) Set(key string, value []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	loc, err := d.append(kindPut, key, value)
+	if err != nil {
+		return err
+	}
+
+	d.put(key, loc)
+
+	return d.finish()
+}
+
+// Delete appends the tombstone of key, if it is in the index.
+func (d *Disk) Delete(key string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.index.Contains(key) {
+		return false, nil
+	}
+
+	if _, err := d.append(kindDelete, key, nil); err != nil {
+		return false, err
+	}
+
+	d.index.Delete(key)
+
+	return true, d.finish()
+}
+
+// finish returns the error of the evictions, if any, and compacts the segments.
+func (d *Disk) finish() error {
+	if err := d.err; err != nil {
+		d.err = nil
+
+		return err
+	}
+
+	return d.compact()
+}
+
+// Len returns the number of keys on disk.
+func (d *Disk) Len() int32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.index.Len()
+}
+
+// Sync flushes the last segment to the disk, the older ones were synced when the next one started.
+func (d *Disk) Sync() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.segments[len(d.segments)-1].f.Sync()
+}
+
+// Close syncs and closes the segments.
+func (d *Disk) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err := d.segments[len(d.segments)-1].f.Sync()
+
+	return errors.Join(err, d.close())
+}
+
+func (d *Disk) close() error {
+	var errs []error
+
+	for _, seg := range d.segments {
+		errs = append(errs, seg.f.Close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,319 @@
+package tiered
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func open(t *testing.T, dir string, capacity int32) *Disk {
+	t.Helper()
+
+	d, err := Open(dir, capacity)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	return d
+}
+
+func reopen(t *testing.T, d *Disk, capacity int32) *Disk {
+	t.Helper()
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	return open(t, d.dir, capacity)
+}
+
+func expectValue(t *testing.T, d *Disk, key, expected string) {
+	t.Helper()
+
+	v, ok, err := d.Get(key)
+	if err != nil || !ok || string(v) != expected {
+		t.Errorf("expected %s to be %q, got %q, %v, %v", key, expected, v, ok, err)
+	}
+}
+
+func expectMissing(t *testing.T, d *Disk, key string) {
+	t.Helper()
+
+	if v, ok, err := d.Get(key); ok || err != nil {
+		t.Errorf("expected %s to be missing, got %q, %v", key, v, err)
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestDiskRecover(t *testing.T) {
+	d := open(t, t.TempDir(), 10)
+
+	for i := range 5 {
+		if err := d.Set(fmt.Sprint(i), []byte(fmt.Sprint("value", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_ = d.Set("1", []byte("new"))
+
+	if ok, err := d.Delete("2"); !ok || err != nil {
+		t.Errorf("expected 2 to be deleted, got %v, %v", ok, err)
+	}
+
+	d = reopen(t, d, 10)
+	defer d.Close()
+
+	if d.Len() != 4 {
+		t.Errorf("expected 4 keys, got %d", d.Len())
+	}
+
+	expectValue(t, d, "0", "value0")
+	expectValue(t, d, "1", "new")
+	expectMissing(t, d, "2")
+	expectValue(t, d, "4", "value4")
+}
+
+func TestDiskEvictionSurvivesReopen(t *testing.T) {
+	d := open(t, t.TempDir(), 2)
+
+	_ = d.Set("a", []byte("a"))
+	_ = d.Set("b", []byte("b"))
+	d.Get("a")
+	_ = d.Set("c", []byte("c"))
+
+	expectMissing(t, d, "b")
+
+	// a larger capacity must not bring b back
+	d = reopen(t, d, 10)
+	defer d.Close()
+
+	expectMissing(t, d, "b")
+	expectValue(t, d, "a", "a")
+	expectValue(t, d, "c", "c")
+}
+
+func TestDiskRestartKeepsLoggedVictims(t *testing.T) {
+	d := open(t, t.TempDir(), 3)
+
+	_ = d.Set("a", []byte("a"))
+	_ = d.Set("b", []byte("b"))
+	_ = d.Set("c", []byte("c"))
+	d.Get("a")
+	_ = d.Set("d", []byte("d"))
+
+	expectMissing(t, d, "b")
+
+	// the visited bit of a is not on disk, the replay must follow the tombstone of b instead of evicting a
+	d = reopen(t, d, 3)
+	defer d.Close()
+
+	for _, k := range []string{"a", "c", "d"} {
+		expectValue(t, d, k, k)
+	}
+
+	expectMissing(t, d, "b")
+}
+
+func TestDiskSmallerCapacity(t *testing.T) {
+	d := open(t, t.TempDir(), 4)
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		_ = d.Set(k, []byte(k))
+	}
+
+	d = reopen(t, d, 2)
+	if d.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", d.Len())
+	}
+
+	d = reopen(t, d, 4)
+	defer d.Close()
+
+	if d.Len() != 2 {
+		t.Errorf("expected the evicted keys to stay evicted, got %d keys", d.Len())
+	}
+}
+
+func TestDiskTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	d := open(t, dir, 10)
+
+	_ = d.Set("a", []byte("a"))
+	_ = d.Set("b", []byte("b"))
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := segmentFiles(t, dir)
+	last := files[len(files)-1]
+
+	info, err := os.Stat(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the crash happened in the middle of the record of c
+	rec := encode(kindPut, "c", []byte("c"))
+
+	f, err := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.Write(rec[:len(rec)-1])
+	f.Close()
+
+	d = open(t, dir, 10)
+
+	expectValue(t, d, "a", "a")
+	expectValue(t, d, "b", "b")
+	expectMissing(t, d, "c")
+
+	if info2, _ := os.Stat(last); info2.Size() != info.Size() {
+		t.Errorf("expected the torn record to be truncated, got size %d instead of %d", info2.Size(), info.Size())
+	}
+
+	_ = d.Set("d", []byte("d"))
+
+	d = reopen(t, d, 10)
+	defer d.Close()
+
+	expectValue(t, d, "d", "d")
+}
+
+func TestDiskChecksum(t *testing.T) {
+	dir := t.TempDir()
+	d := open(t, dir, 10)
+
+	_ = d.Set("a", []byte("a"))
+	_ = d.Set("b", []byte("b"))
+
+	files := segmentFiles(t, dir)
+
+	f, err := os.OpenFile(files[0], os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the value of b is the last byte of the file
+	loc, _ := d.index.Peek("b")
+	_, _ = f.WriteAt([]byte("x"), loc.offset+loc.size-1)
+	f.Close()
+
+	if _, _, err := d.Get("b"); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+
+	d = reopen(t, d, 10)
+	defer d.Close()
+
+	expectValue(t, d, "a", "a")
+	expectMissing(t, d, "b")
+}
+
+func TestDiskCompaction(t *testing.T) {
+	dir := t.TempDir()
+	d := open(t, dir, 100).WithSegmentSize(256)
+
+	for i := range 1000 {
+		if err := d.Set(fmt.Sprint(i%5), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// five live records of about 20 bytes, the dead bytes are at most a segment more than them
+	if n := len(segmentFiles(t, dir)); n > 4 {
+		t.Errorf("expected the dead segments to be removed, got %d", n)
+	}
+
+	for k := range 5 {
+		expectValue(t, d, fmt.Sprint(k), fmt.Sprint(995+k))
+	}
+
+	d = reopen(t, d, 100)
+	defer d.Close()
+
+	if d.Len() != 5 {
+		t.Errorf("expected 5 keys, got %d", d.Len())
+	}
+
+	for k := range 5 {
+		expectValue(t, d, fmt.Sprint(k), fmt.Sprint(995+k))
+	}
+}
+
+func TestDiskCompactionMovesLiveRecords(t *testing.T) {
+	dir := t.TempDir()
+	d := open(t, dir, 100).WithSegmentSize(256)
+
+	// a never changes, its segment is compacted by copying it forward
+	_ = d.Set("a", []byte("a"))
+
+	for i := range 1000 {
+		_ = d.Set("b", []byte(fmt.Sprint(i)))
+	}
+
+	if n := len(segmentFiles(t, dir)); n > 4 {
+		t.Errorf("expected the old segments to be compacted, got %d", n)
+	}
+
+	expectValue(t, d, "a", "a")
+
+	d = reopen(t, d, 100)
+	defer d.Close()
+
+	expectValue(t, d, "a", "a")
+	expectValue(t, d, "b", "999")
+}
+
+func TestDiskCorruptedSegment(t *testing.T) {
+	dir := t.TempDir()
+	d := open(t, dir, 100).WithSegmentSize(64)
+
+	for i := range 10 {
+		_ = d.Set(fmt.Sprint(i), []byte("value"))
+	}
+
+	// the tombstone is in a later segment than the put of 0
+	if _, err := d.Delete("0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := segmentFiles(t, dir)
+	if len(files) < 2 {
+		t.Fatalf("expected several segments, got %v", files)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(data)-1] ^= 0xff
+
+	if err := os.WriteFile(files[0], data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the last segment can end with a torn record
+	if _, err := Open(dir, 100); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package tiered
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksum is returned when a record read from disk does not match its checksum.
+var ErrChecksum = errors.New("tiered: checksum mismatch")
+
+const (
+	// headerSize is the size of the header of a record: checksum, kind, key length and value length.
+	headerSize = 4 + 1 + 4 + 4
+
+	// maxRecordSize is a sanity limit, a larger length in a header means the header is garbage.
+	maxRecordSize = 1 << 30
+
+	kindPut    byte = 1
+	kindDelete byte = 2
+
+	segmentExt = ".seg"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// segment is one log file, only the last one is written.
+type segment struct {
+	id uint64
+	f  *os.File
+
+	// size is where the next record goes, live the size of the records the index points to.
+	size int64
+	live int64
+}
+
+func segmentName(id uint64) string {
+	return fmt.Sprintf("%016x%s", id, segmentExt)
+}
+
+// segmentIDs returns the ids of the segments in dir, from the oldest.
+func segmentIDs(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+
+	// ReadDir sorts by name, and the names are the ids in fixed width hex
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+
+		id, err := strconv.ParseUint(name, 16, 64)
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func openSegment(dir string, id uint64, flag int) (*segment, error) {
+	f, err := os.OpenFile(filepath.Join(dir, segmentName(id)), os.O_RDWR|flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segment{id: id, f: f, size: 0, live: 0}, nil
+}
+
+// record is a put of a value or the tombstone of a key.
+type record struct {
+	kind  byte
+	key   string
+	value []byte
+}
+
+// encode returns the bytes of a record, the checksum covers everything after itself.
+func encode(kind byte, key string, value []byte) []byte {
+	buf := make([]byte, headerSize+len(key)+len(value))
+	buf[4] = kind
+	binary.LittleEndian.PutUint32(buf[5:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(buf[9:], uint32(len(value)))
+	copy(buf[headerSize:], key)
+	copy(buf[headerSize+len(key):], value)
+	binary.LittleEndian.PutUint32(buf, crc32.Checksum(buf[4:], castagnoli))
+
+	return buf
+}
+
+// decode parses the bytes of one whole record.
+func decode(buf []byte) (record, error) {
+	if len(buf) < headerSize || binary.LittleEndian.Uint32(buf) != crc32.Checksum(buf[4:], castagnoli) {
+		return record{}, ErrChecksum
+	}
+
+	keyLen := binary.LittleEndian.Uint32(buf[5:])
+
+	return record{kind: buf[4], key: string(buf[headerSize : headerSize+keyLen]), value: buf[headerSize+keyLen:]}, nil
+}
+
+// readRecord reads the next record of a segment and its size.
+// It returns io.EOF at the end, and io.ErrUnexpectedEOF or ErrChecksum for a torn or corrupted record.
+func readRecord(r *bufio.Reader) (record, int64, error) {
+	header, err := r.Peek(headerSize)
+	if err != nil {
+		if len(header) == 0 && errors.Is(err, io.EOF) {
+			return record{}, 0, io.EOF
+		}
+
+		return record{}, 0, io.ErrUnexpectedEOF
+	}
+
+	size := int64(headerSize) + int64(binary.LittleEndian.Uint32(header[5:])) + int64(binary.LittleEndian.Uint32(header[9:]))
+	if size > maxRecordSize || (header[4] != kindPut && header[4] != kindDelete) {
+		return record{}, 0, ErrChecksum
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return record{}, 0, io.ErrUnexpectedEOF
+	}
+
+	rec, err := decode(buf)
+
+	return rec, size, err
+}
@@ -0,0 +1,165 @@
+// Package tiered puts a sieve in memory in front of a sieve on local disk, for working sets larger than RAM.
+//
+// The keys evicted from memory by capacity are demoted to disk, and a key found on disk is promoted back
+// to memory, so a key lives in one tier at a time.
+package tiered
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/guerinoni/sieve"
+)
+
+// Stats are the counters of a tiered cache.
+type Stats struct {
+	MemoryHits int64
+	DiskHits   int64
+	Misses     int64
+	// Demotions counts the keys moved from memory to disk, Promotions the ones moved back.
+	Demotions  int64
+	Promotions int64
+}
+
+// Cache is a tiered cache of byte values. The disk is read and written under the lock of the cache.
+type Cache struct {
+	mem  *sieve.Cache[string, []byte]
+	disk *Disk
+
+	stats Stats
+
+	// err is the first error of the demotions made by the last operation
+	err error
+
+	mu sync.Mutex
+}
+
+// New returns a cache keeping size keys in memory in front of disk.
+// The cache owns the disk from now on: Close closes it.
+// If the size is less than or equal to zero, it panics.
+func New(size int32, disk *Disk) *Cache {
+	c := &Cache{
+		mem:   nil,
+		disk:  disk,
+		stats: Stats{MemoryHits: 0, DiskHits: 0, Misses: 0, Demotions: 0, Promotions: 0},
+		err:   nil,
+		mu:    sync.Mutex{},
+	}
+
+	c.mem = sieve.NewSingleThread[string, []byte](size).OnEvict(c.demote)
+
+	return c
+}
+
+// demote writes a key evicted from memory to disk.
+func (c *Cache) demote(key string, value []byte, reason sieve.EvictReason) {
+	if reason != sieve.Evicted {
+		return
+	}
+
+	if err := c.disk.Set(key, value); err != nil {
+		c.err = errors.Join(c.err, err)
+
+		return
+	}
+
+	c.stats.Demotions++
+}
+
+// Get returns the value of key from memory, or from disk moving it to memory.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.mem.Get(key); ok {
+		c.stats.MemoryHits++
+
+		return v, true, nil
+	}
+
+	v, ok, err := c.disk.Get(key)
+	if err != nil || !ok {
+		if err == nil {
+			c.stats.Misses++
+		}
+
+		return nil, false, err
+	}
+
+	c.stats.DiskHits++
+
+	if _, err := c.disk.Delete(key); err != nil {
+		return v, true, err
+	}
+
+	c.mem.Set(key, v)
+
+	c.stats.Promotions++
+
+	return v, true, c.takeErr()
+}
+
+// Set stores value in memory, dropping the copy on disk if any.
+func (c *Cache) Set(key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mem.Set(key, value)
+
+	_, err := c.disk.Delete(key)
+
+	return errors.Join(err, c.takeErr())
+}
+
+// Delete removes key from both tiers.
+func (c *Cache) Delete(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	inMemory := c.mem.Delete(key)
+	onDisk, err := c.disk.Delete(key)
+
+	return inMemory || onDisk, err
+}
+
+// Len returns the number of keys in both tiers.
+func (c *Cache) Len() int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.mem.Len() + c.disk.Len()
+}
+
+// Stats returns the counters of the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// Close demotes the keys in memory to disk, so that they are still there when the disk is opened again,
+// and closes the disk.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+
+	// from the tail, so that the newest keys are the last ones written
+	entries := c.mem.Entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		errs = append(errs, c.disk.Set(entries[i].Key, entries[i].Value))
+	}
+
+	c.mem.Flush()
+
+	return errors.Join(append(errs, c.disk.Close())...)
+}
+
+func (c *Cache) takeErr() error {
+	err := c.err
+	c.err = nil
+
+	return err
+}
@@ -0,0 +1,125 @@
+package tiered
+
+import (
+	"testing"
+)
+
+func newCache(t *testing.T, dir string) *Cache {
+	t.Helper()
+
+	return New(2, open(t, dir, 10))
+}
+
+func expect(t *testing.T, c *Cache, key, expected string) {
+	t.Helper()
+
+	v, ok, err := c.Get(key)
+	if err != nil || !ok || string(v) != expected {
+		t.Errorf("expected %s to be %q, got %q, %v, %v", key, expected, v, ok, err)
+	}
+}
+
+func TestDemoteAndPromote(t *testing.T) {
+	c := newCache(t, t.TempDir())
+	defer c.Close()
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := c.Set(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a was evicted from memory to disk
+	if !c.disk.index.Contains("a") || c.mem.Contains("a") {
+		t.Errorf("expected a on disk only")
+	}
+
+	if c.Len() != 3 {
+		t.Errorf("expected 3 keys, got %d", c.Len())
+	}
+
+	expect(t, c, "a", "a")
+
+	// a is back in memory, and b went to disk to make room
+	if c.disk.index.Contains("a") || !c.mem.Contains("a") || !c.disk.index.Contains("b") {
+		t.Errorf("expected a to be promoted and b demoted")
+	}
+
+	expect(t, c, "a", "a")
+
+	if _, ok, _ := c.Get("z"); ok {
+		t.Errorf("expected z to be missing")
+	}
+
+	expected := Stats{MemoryHits: 1, DiskHits: 1, Misses: 1, Demotions: 2, Promotions: 1}
+	if got := c.Stats(); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestSetReplacesTheDiskCopy(t *testing.T) {
+	dir := t.TempDir()
+	c := newCache(t, dir)
+
+	_ = c.Set("a", []byte("old"))
+	_ = c.Set("b", []byte("b"))
+	_ = c.Set("c", []byte("c"))
+
+	// a is on disk with the old value
+	_ = c.Set("a", []byte("new"))
+
+	if c.disk.index.Contains("a") {
+		t.Errorf("expected the old a to be dropped from disk")
+	}
+
+	// without Close the memory is lost, the old value must not come back
+	c.disk.Close()
+
+	d := open(t, dir, 10)
+	defer d.Close()
+
+	if v, ok, _ := d.Get("a"); ok {
+		t.Errorf("expected the old a to stay deleted, got %q", v)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := newCache(t, t.TempDir())
+	defer c.Close()
+
+	_ = c.Set("a", []byte("a"))
+	_ = c.Set("b", []byte("b"))
+	_ = c.Set("c", []byte("c"))
+
+	for _, k := range []string{"a", "c"} {
+		if ok, err := c.Delete(k); !ok || err != nil {
+			t.Errorf("expected %s to be deleted, got %v, %v", k, ok, err)
+		}
+	}
+
+	if ok, _ := c.Delete("a"); ok {
+		t.Errorf("expected a to be already deleted")
+	}
+
+	if c.Len() != 1 {
+		t.Errorf("expected 1 key, got %d", c.Len())
+	}
+}
+
+func TestCloseKeepsTheMemory(t *testing.T) {
+	dir := t.TempDir()
+	c := newCache(t, dir)
+
+	_ = c.Set("a", []byte("a"))
+	_ = c.Set("b", []byte("b"))
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newCache(t, dir)
+	defer c.Close()
+
+	expect(t, c, "a", "a")
+	expect(t, c, "b", "b")
+}
@@ -0,0 +1,150 @@
+package sieve
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCorrupted is wrapped by the errors returned by Validate.
+var ErrCorrupted = errors.New("sieve: corrupted")
+
+// Validate checks the internal invariants of the sieve and returns the first broken one, wrapping ErrCorrupted.
+// It walks the whole sieve under the lock, so it is meant for tests, fuzzing and debugging.
+func (s *Cache[K, V]) Validate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validateList(); err != nil {
+		return err
+	}
+
+	return s.validateIndexes()
+}
+
+// validateList checks the links, the maps, len and the hand.
+func (s *Cache[K, V]) validateList() error {
+	if (s.head == nil) != (s.tail == nil) {
+		return fmt.Errorf("%w: only one of head and tail is nil", ErrCorrupted)
+	}
+
+	if s.head != nil && s.head.prev != nil {
+		return fmt.Errorf("%w: head has a prev link", ErrCorrupted)
+	}
+
+	if s.tail != nil && s.tail.next != nil {
+		return fmt.Errorf("%w: tail has a next link", ErrCorrupted)
+	}
+
+	// the nodes of the sieve and of its namespaces are on the same list
+	keys := len(s.m)
+	for _, ns := range s.namespaces {
+		keys += len(ns.m)
+	}
+
+	count := 0
+	handFound := false
+
+	var last *node[K, V]
+
+	for n := s.head; n != nil; n = n.next {
+		count++
+
+		// a cycle would make the walk go on forever
+		if count > keys {
+			return fmt.Errorf("%w: the list has more nodes than the maps", ErrCorrupted)
+		}
+
+		if n.next != nil && n.next.prev != n {
+			return fmt.Errorf("%w: the prev link of the node after %v does not point back", ErrCorrupted, n.key)
+		}
+
+		if ns := n.ns(); ns != nil && s.namespaces[ns.name] != ns {
+			return fmt.Errorf("%w: node %v is in an unknown namespace", ErrCorrupted, n.key)
+		}
+
+		if m, ok := s.keyspace(n.ns())[n.key]; !ok || m != n {
+			return fmt.Errorf("%w: node %v is not the one in the map", ErrCorrupted, n.key)
+		}
+
+		if n == s.hand {
+			handFound = true
+		}
+
+		last = n
+	}
+
+	if last != s.tail {
+		return fmt.Errorf("%w: the list does not end at tail", ErrCorrupted)
+	}
+
+	if count != keys {
+		return fmt.Errorf("%w: the list has %d nodes and the maps %d", ErrCorrupted, count, keys)
+	}
+
+	if int32(count) != s.Len() {
+		return fmt.Errorf("%w: the list has %d nodes and len is %d", ErrCorrupted, count, s.Len())
+	}
+
+	if s.Len() > s.capacity {
+		return fmt.Errorf("%w: len %d is over the capacity %d", ErrCorrupted, s.Len(), s.capacity)
+	}
+
+	if (s.hand == nil) != (count == 0) {
+		return fmt.Errorf("%w: the hand is nil only when the sieve is empty", ErrCorrupted)
+	}
+
+	if s.hand != nil && !handFound {
+		return fmt.Errorf("%w: the hand is not on the list", ErrCorrupted)
+	}
+
+	return nil
+}
+
+// validateIndexes checks the pinned count, the tag index and the prefix index against the nodes.
+func (s *Cache[K, V]) validateIndexes() error {
+	pinned := int32(0)
+	tagged := 0
+
+	for n := s.head; n != nil; n = n.next {
+		if n.pinned {
+			pinned++
+		}
+
+		for _, t := range n.tags() {
+			if _, ok := s.tags[t][n]; !ok {
+				return fmt.Errorf("%w: node %v is missing from the index of tag %s", ErrCorrupted, n.key, t)
+			}
+		}
+
+		tagged += len(n.tags())
+
+		if s.prefixes != nil && n.ns() == nil && !s.prefixes.contains(n) {
+			return fmt.Errorf("%w: node %v is missing from the prefix index", ErrCorrupted, n.key)
+		}
+	}
+
+	if pinned != s.pinned.Load() {
+		return fmt.Errorf("%w: %d nodes are pinned and the count is %d", ErrCorrupted, pinned, s.pinned.Load())
+	}
+
+	if pinned > s.maxPinned {
+		return fmt.Errorf("%w: %d nodes are pinned over the limit %d", ErrCorrupted, pinned, s.maxPinned)
+	}
+
+	indexed := 0
+	for _, nodes := range s.tags {
+		indexed += len(nodes)
+	}
+
+	if indexed != tagged {
+		return fmt.Errorf("%w: the tag index has %d entries and the nodes %d tags", ErrCorrupted, indexed, tagged)
+	}
+
+	if s.prefixes != nil {
+		if n := len(s.prefixes.withPrefix("")); n != len(s.m) {
+			return fmt.Errorf("%w: the prefix index has %d nodes and the map %d", ErrCorrupted, n, len(s.m))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package sieve
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s := WithPrefixIndex(New[string, int](4))
+	ns := s.Namespace("ns")
+
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for i := range 5000 {
+		k := fmt.Sprintf("k%d", r.IntN(8))
+
+		switch r.IntN(10) {
+		case 0, 1, 2:
+			s.Set(k, i)
+		case 3, 4:
+			s.Get(k)
+		case 5:
+			s.Delete(k)
+		case 6:
+			s.SetWithTags(k, i, "a", "b", "a")
+		case 7:
+			if r.IntN(2) == 0 {
+				s.Pin(k)
+			} else {
+				s.Unpin(k)
+			}
+		case 8:
+			ns.Set(k, i)
+
+			if r.IntN(4) == 0 {
+				ns.Flush()
+			}
+		case 9:
+			switch r.IntN(20) {
+			case 0:
+				s.Flush()
+			case 1:
+				s.InvalidateTag("a")
+			case 2:
+				DeletePrefix(s, "k1")
+			}
+		}
+
+		if err := s.Validate(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+}
+
+func TestValidateCorrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(s *Cache[int, int])
+	}{
+		{"broken prev link", func(s *Cache[int, int]) { s.head.next.prev = nil }},
+		{"head with prev", func(s *Cache[int, int]) { s.head.prev = s.tail }},
+		{"tail with next", func(s *Cache[int, int]) { s.tail.next = s.head }},
+		{"missing tail", func(s *Cache[int, int]) { s.tail = nil }},
+		{"wrong tail", func(s *Cache[int, int]) { s.tail = s.head }},
+		{"node not in map", func(s *Cache[int, int]) { delete(s.m, s.head.key) }},
+		{"extra node in map", func(s *Cache[int, int]) { s.m[42] = newNode(42, 42) }},
+		{"wrong len", func(s *Cache[int, int]) { s.len.Add(1) }},
+		{"hand off list", func(s *Cache[int, int]) { s.hand = newNode(42, 42) }},
+		{"nil hand", func(s *Cache[int, int]) { s.hand = nil }},
+		{"pinned count", func(s *Cache[int, int]) { s.head.pinned = true }},
+		{"tag index", func(s *Cache[int, int]) { s.head.ext().tags = []string{"x"} }},
+		{"extra tag", func(s *Cache[int, int]) { s.tags = map[string]map[*node[int, int]]struct{}{"x": {s.head: {}}} }},
+		{"more nodes than keys", func(s *Cache[int, int]) { delete(s.m, s.tail.key) }},
+		{"unknown namespace", func(s *Cache[int, int]) { s.head.ext().ns = &namespace[int, int]{name: "x", gen: 0, m: nil} }},
+		{"tail off list", func(s *Cache[int, int]) { s.tail = newNode(42, 42) }},
+		{"over capacity", func(s *Cache[int, int]) { s.capacity = 2 }},
+		{"over pin limit", func(s *Cache[int, int]) { s.Pin(1); s.maxPinned = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New[int, int](4)
+			s.Set(1, 1)
+			s.Set(2, 2)
+			s.Set(3, 3)
+
+			if err := s.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.corrupt(s)
+
+			if err := s.Validate(); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("expected ErrCorrupted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidatePrefixIndex(t *testing.T) {
+	s := WithPrefixIndex(New[string, int](4))
+	s.Set("a", 1)
+	s.Set("b", 2)
+
+	s.prefixes.remove(s.m["a"])
+
+	if err := s.Validate(); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted, got %v", err)
+	}
+
+	// the other way around, a key in the index only
+	s.prefixes.insert(s.m["a"])
+	s.prefixes.insert(newNode("c", 3))
+
+	if err := s.Validate(); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted, got %v", err)
+	}
+}
@@ -0,0 +1,1121 @@
+package sieve
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy tells when the write-ahead log is synced to disk.
+type SyncPolicy int
+
+const (
+	// SyncBatch syncs after every batch, a crash loses at most the batch not yet written.
+	SyncBatch SyncPolicy = iota
+	// SyncPeriodic syncs once per second, a crash of the machine loses at most the last second.
+	SyncPeriodic
+	// SyncNone leaves the syncs to the OS, only Flush and Close sync.
+	SyncNone
+)
+
+// Codec turns the keys or the values of a sieve into bytes for the write-ahead log.
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec is a Codec using encoding/json.
+type JSONCodec[T any] struct{}
+
+// Marshal returns the JSON encoding of v.
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal parses the JSON encoding of a T.
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+
+	err := json.Unmarshal(data, &v)
+
+	return v, err
+}
+
+// WALOption configures a write-ahead log opened by OpenWAL.
+type WALOption func(*walConfig)
+
+type walConfig struct {
+	sync      SyncPolicy
+	batch     int
+	interval  time.Duration
+	compactAt int64
+}
+
+// WithSyncPolicy sets when the log is synced, SyncBatch by default.
+func WithSyncPolicy(policy SyncPolicy) WALOption {
+	return func(c *walConfig) {
+		c.sync = policy
+	}
+}
+
+// WithBatch sets how many records are written together, 128 by default,
+// and how long a record waits for the batch to fill, 10ms by default.
+func WithBatch(records int, interval time.Duration) WALOption {
+	return func(c *walConfig) {
+		c.batch = records
+		c.interval = interval
+	}
+}
+
+// WithCompactAt sets the size of the log after which it is compacted into a snapshot, 64 MiB by default.
+func WithCompactAt(bytes int64) WALOption {
+	return func(c *walConfig) {
+		c.compactAt = bytes
+	}
+}
+
+func (c *walConfig) validate() error {
+	if c.sync < SyncBatch || c.sync > SyncNone {
+		return &ConfigError{Option: "sync policy", Reason: "is unknown"}
+	}
+
+	if c.batch <= 0 || c.interval <= 0 {
+		return &ConfigError{Option: "batch", Reason: "must be greater than zero"}
+	}
+
+	if c.compactAt <= 0 {
+		return &ConfigError{Option: "compact at", Reason: "must be greater than zero"}
+	}
+
+	return nil
+}
+
+// journal receives the changes of a sieve, under its lock.
+type journal[K comparable, V any] interface {
+	set(n *node[K, V])
+	visit(n *node[K, V], atNow time.Time)
+	remove(n *node[K, V])
+	evict(n *node[K, V])
+	flush()
+	namespace(ns *namespace[K, V])
+}
+
+func (s *Cache[K, V]) logSet(n *node[K, V]) {
+	if s.journal != nil {
+		s.journal.set(n)
+	}
+}
+
+// logVisit records the visited bit going from false to true, not the reads of visited nodes.
+func (s *Cache[K, V]) logVisit(n *node[K, V], atNow time.Time) {
+	if s.journal != nil && !n.visited {
+		s.journal.visit(n, atNow)
+	}
+}
+
+func (s *Cache[K, V]) logRemove(n *node[K, V]) {
+	if s.journal != nil {
+		s.journal.remove(n)
+	}
+}
+
+func (s *Cache[K, V]) logEvict(n *node[K, V]) {
+	if s.journal != nil {
+		s.journal.evict(n)
+	}
+}
+
+func (s *Cache[K, V]) logFlush() {
+	if s.journal != nil {
+		s.journal.flush()
+	}
+}
+
+// logNamespace records the generation of a flushed namespace.
+func (s *Cache[K, V]) logNamespace(ns *namespace[K, V]) {
+	if s.journal != nil {
+		s.journal.namespace(ns)
+	}
+}
+
+// the kinds of the records of the log
+const (
+	recSet byte = iota + 1
+	recVisit
+	recRemove
+	recEvict
+	recFlush
+	recNamespace
+)
+
+const (
+	// frameHeader is the checksum and the length of a frame, a batch of records or a snapshot.
+	frameHeader = 8
+	// maxFrame is a sanity limit, a larger length in a header means the header is garbage.
+	maxFrame = 1 << 30
+
+	snapshotName = "snapshot"
+	logPrefix    = "wal-"
+	logExt       = ".log"
+)
+
+// the flags of a set record
+const (
+	flagVisited byte = 1 << iota
+	flagAbsent
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// WAL is a write-ahead log of the changes of a sieve: the writes, the deletes, the evictions and expirations
+// and the visited bits set by the reads, enough to rebuild the same sieve, hand included.
+// The records are written in batches, each with a checksum, and the log is compacted into a snapshot
+// once it is large enough. Tags and pins are not recorded, and a read of a key
+// already visited is not either, so a sliding TTL counts from the first read after the last lap of the hand.
+type WAL[K comparable, V any] struct {
+	s      *Cache[K, V]
+	dir    string
+	keys   Codec[K]
+	values Codec[V]
+	cfg    walConfig
+
+	// f is the log of generation gen, size its size.
+	f    *os.File
+	gen  uint64
+	size int64
+
+	// buf holds the records of the next batch.
+	buf     []byte
+	pending int
+
+	// dirty is true when a batch was written since the last sync.
+	dirty    bool
+	lastSync time.Time
+
+	// compactDue asks the next record to compact the log first, once the changes logged so far are applied.
+	compactDue bool
+
+	// err is the first error, the log is unusable after it.
+	err error
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenWAL rebuilds s from the snapshot and the logs in dir, then logs every change of s there until Close.
+// The sieve should be empty and built with the same options as the one that wrote the log;
+// OnEvict is not called for the keys evicted while the log is replayed.
+func OpenWAL[K comparable, V any](s *Cache[K, V], dir string, keys Codec[K], values Codec[V], opts ...WALOption) (*WAL[K, V], error) {
+	cfg := walConfig{sync: SyncBatch, batch: 128, interval: 10 * time.Millisecond, compactAt: 64 << 20}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	w := &WAL[K, V]{
+		s:          s,
+		dir:        dir,
+		keys:       keys,
+		values:     values,
+		cfg:        cfg,
+		f:          nil,
+		gen:        0,
+		size:       0,
+		buf:        nil,
+		pending:    0,
+		dirty:      false,
+		lastSync:   time.Now(),
+		compactDue: false,
+		err:        nil,
+		mu:         sync.Mutex{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := w.restore(); err != nil {
+		return nil, err
+	}
+
+	s.journal = w
+
+	go w.run()
+
+	return w, nil
+}
+
+// restore applies the snapshot and the logs after it, and opens the last log to append to it.
+func (w *WAL[K, V]) restore() error {
+	onEvict := w.s.onEvict
+	w.s.onEvict = nil
+
+	defer func() { w.s.onEvict = onEvict }()
+
+	if err := w.readSnapshot(); err != nil {
+		return err
+	}
+
+	gens, err := w.logGens()
+	if err != nil {
+		return err
+	}
+
+	for i, gen := range gens {
+		// a crash during a compaction leaves the logs the snapshot already covers
+		if gen < w.gen {
+			if err := os.Remove(w.logPath(gen)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := w.replay(gen, i == len(gens)-1); err != nil {
+			return err
+		}
+
+		w.gen = gen
+	}
+
+	f, err := openFile(w.logPath(w.gen), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	w.f = f
+	w.size = info.Size()
+
+	return nil
+}
+
+func (w *WAL[K, V]) logPath(gen uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s%016x%s", logPrefix, gen, logExt))
+}
+
+// logGens returns the generations of the logs in dir, from the oldest.
+func (w *WAL[K, V]) logGens() ([]uint64, error) {
+	entries, err := readDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var gens []uint64
+
+	// ReadDir sorts by name, and the names are the generations in fixed width hex
+	for _, e := range entries {
+		name, ok := strings.CutPrefix(e.Name(), logPrefix)
+		if !ok {
+			continue
+		}
+
+		name, ok = strings.CutSuffix(name, logExt)
+		if !ok {
+			continue
+		}
+
+		if gen, err := strconv.ParseUint(name, 16, 64); err == nil {
+			gens = append(gens, gen)
+		}
+	}
+
+	return gens, nil
+}
+
+func (w *WAL[K, V]) readSnapshot() error {
+	f, err := openFile(filepath.Join(w.dir, snapshotName), os.O_RDONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	payload, err := readFrame(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("%w: snapshot: %w", ErrCorrupted, err)
+	}
+
+	d := &decoder{b: payload, err: nil}
+	w.gen = d.uvarint()
+	count := d.uvarint()
+	hand := d.varint()
+
+	for range d.uvarint() {
+		if d.byte() != recNamespace {
+			return fmt.Errorf("%w: snapshot: unexpected record", ErrCorrupted)
+		}
+
+		w.applyNamespace(d)
+	}
+
+	// from the tail, so that each node goes in front of the older ones
+	var handNode *node[K, V]
+
+	for i := range count {
+		if d.byte() != recSet {
+			return fmt.Errorf("%w: snapshot: unexpected record", ErrCorrupted)
+		}
+
+		n, err := w.applySet(d)
+		if err != nil {
+			return err
+		}
+
+		if int64(i) == hand {
+			handNode = n
+		}
+	}
+
+	if d.err != nil {
+		return fmt.Errorf("%w: snapshot: %w", ErrCorrupted, d.err)
+	}
+
+	if handNode != nil {
+		w.s.hand = handNode
+	}
+
+	return nil
+}
+
+// replay applies the batches of a log, the last one is truncated after its last valid batch.
+func (w *WAL[K, V]) replay(gen uint64, last bool) error {
+	f, err := openFile(w.logPath(gen), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var offset int64
+
+	for {
+		payload, err := readFrame(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			// a crash in the middle of a batch, the rest was never synced
+			if last {
+				return f.Truncate(offset)
+			}
+
+			return fmt.Errorf("%w: log %d at %d: %w", ErrCorrupted, gen, offset, err)
+		}
+
+		if err := w.apply(payload); err != nil {
+			return fmt.Errorf("log %d at %d: %w", gen, offset, err)
+		}
+
+		offset += int64(frameHeader + len(payload))
+	}
+}
+
+// apply replays the records of a batch.
+func (w *WAL[K, V]) apply(payload []byte) error {
+	d := &decoder{b: payload, err: nil}
+
+	for len(d.b) > 0 && d.err == nil {
+		kind := d.byte()
+
+		switch kind {
+		case recSet:
+			if _, err := w.applySet(d); err != nil {
+				return err
+			}
+		case recVisit, recRemove, recEvict:
+			name, inNamespace := d.namespace()
+			b, at := d.bytes(), d.time()
+			if d.err != nil {
+				break
+			}
+
+			key, err := w.keys.Unmarshal(b)
+			if err != nil {
+				return err
+			}
+
+			var ns *namespace[K, V]
+			if inNamespace {
+				ns = w.s.namespace(name)
+			}
+
+			w.applyKey(kind, ns, key, at)
+		case recFlush:
+			w.s.reset()
+		case recNamespace:
+			w.applyNamespace(d)
+		default:
+			return fmt.Errorf("%w: unknown record %d", ErrCorrupted, kind)
+		}
+	}
+
+	if d.err != nil {
+		return fmt.Errorf("%w: %w", ErrCorrupted, d.err)
+	}
+
+	return nil
+}
+
+// applyNamespace sets the generation of a namespace.
+func (w *WAL[K, V]) applyNamespace(d *decoder) {
+	name, _ := d.namespace()
+	gen := d.uvarint()
+
+	if d.err == nil {
+		w.s.namespace(name).gen = gen
+	}
+}
+
+// applySet writes the node of a set record as it was, inserting it at the head if it is new.
+func (w *WAL[K, V]) applySet(d *decoder) (*node[K, V], error) {
+	name, inNamespace := d.namespace()
+
+	var gen uint64
+	if inNamespace {
+		gen = d.uvarint()
+	}
+
+	kb, vb := d.bytes(), d.bytes()
+	flags := d.byte()
+	access, written, expiresAt := d.time(), d.time(), d.time()
+
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupted, d.err)
+	}
+
+	key, err := w.keys.Unmarshal(kb)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := w.values.Unmarshal(vb)
+	if err != nil {
+		return nil, err
+	}
+
+	var ns *namespace[K, V]
+	if inNamespace {
+		ns = w.s.namespace(name)
+	}
+
+	n, ok := w.s.keyspace(ns)[key]
+	if !ok {
+		// a sieve smaller than the one that wrote the log
+		if w.s.Len() >= w.s.capacity && !w.s.evictNode() {
+			return nil, nil
+		}
+
+		n = newNode(key, value)
+
+		if ns != nil {
+			n.ext().ns = ns
+		}
+
+		w.s.pushHead(n)
+	}
+
+	if ns != nil {
+		n.extra.gen = gen
+	}
+
+	n.value = value
+	n.visited = flags&flagVisited != 0
+	n.absent = flags&flagAbsent != 0
+	n.access = access
+	n.setTimes(expiresAt, written)
+
+	return n, nil
+}
+
+func (w *WAL[K, V]) applyKey(kind byte, ns *namespace[K, V], key K, at time.Time) {
+	n, ok := w.s.keyspace(ns)[key]
+	if !ok {
+		return
+	}
+
+	switch kind {
+	case recVisit:
+		n.visited = true
+		n.access = at
+	case recRemove:
+		w.s.dropNode(n, Deleted)
+	case recEvict:
+		// the hand moves to the victim clearing the visited bits on its way, as it did when the record was written
+		h := w.s.hand
+		for range w.s.Len() {
+			if h == n {
+				break
+			}
+
+			h.visited = false
+
+			if h = h.prev; h == nil {
+				h = w.s.tail
+			}
+		}
+
+		w.s.hand = n
+		w.s.dropNode(n, Evicted)
+	}
+}
+
+// record appends a record to the batch, writing the batch when it is full.
+func (w *WAL[K, V]) record(kind byte, ns *namespace[K, V], n *node[K, V], at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return
+	}
+
+	// the changes logged so far are in the sieve now, the snapshot can be taken
+	if w.compactDue {
+		w.compactDue = false
+
+		if err := w.compact(); err != nil {
+			w.fail(err)
+
+			return
+		}
+	}
+
+	b, err := w.appendRecord(w.buf, kind, ns, n, at)
+	if err != nil {
+		w.fail(err)
+
+		return
+	}
+
+	w.buf = b
+	w.pending++
+
+	if w.pending >= w.cfg.batch {
+		w.writeBatch()
+	}
+
+	if w.size >= w.cfg.compactAt {
+		w.compactDue = true
+	}
+}
+
+// appendRecord encodes a record of n in ns, or of the generation of ns for recNamespace.
+func (w *WAL[K, V]) appendRecord(b []byte, kind byte, ns *namespace[K, V], n *node[K, V], at time.Time) ([]byte, error) {
+	if kind == recNamespace {
+		return appendGeneration(b, ns), nil
+	}
+
+	b = append(b, kind)
+
+	if kind == recFlush {
+		return b, nil
+	}
+
+	b = appendNamespace(b, ns)
+
+	if kind == recSet && ns != nil {
+		b = binary.AppendUvarint(b, n.extra.gen)
+	}
+
+	key, err := w.keys.Marshal(n.key)
+	if err != nil {
+		return nil, err
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(key)))
+	b = append(b, key...)
+
+	if kind != recSet {
+		return appendTime(b, at), nil
+	}
+
+	value, err := w.values.Marshal(n.value)
+	if err != nil {
+		return nil, err
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	b = append(b, value...)
+
+	var flags byte
+	if n.visited {
+		flags |= flagVisited
+	}
+
+	if n.absent {
+		flags |= flagAbsent
+	}
+
+	b = append(b, flags)
+	b = appendTime(b, n.access)
+	b = appendTime(b, n.written())
+
+	return appendTime(b, n.expiresAt()), nil
+}
+
+func (w *WAL[K, V]) set(n *node[K, V]) {
+	w.record(recSet, n.ns(), n, time.Time{})
+}
+
+func (w *WAL[K, V]) visit(n *node[K, V], atNow time.Time) {
+	w.record(recVisit, n.ns(), n, atNow)
+}
+
+func (w *WAL[K, V]) remove(n *node[K, V]) {
+	w.record(recRemove, n.ns(), n, time.Time{})
+}
+
+func (w *WAL[K, V]) evict(n *node[K, V]) {
+	w.record(recEvict, n.ns(), n, time.Time{})
+}
+
+func (w *WAL[K, V]) flush() {
+	w.record(recFlush, nil, nil, time.Time{})
+}
+
+func (w *WAL[K, V]) namespace(ns *namespace[K, V]) {
+	w.record(recNamespace, ns, nil, time.Time{})
+}
+
+func (w *WAL[K, V]) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// writeBatch writes the pending records as one frame, syncing it if the policy says so.
+func (w *WAL[K, V]) writeBatch() {
+	if w.pending == 0 || w.err != nil {
+		return
+	}
+
+	frame := appendFrame(nil, w.buf)
+
+	if _, err := w.f.Write(frame); err != nil {
+		w.fail(err)
+
+		return
+	}
+
+	w.size += int64(len(frame))
+	w.buf = w.buf[:0]
+	w.pending = 0
+	w.dirty = true
+
+	if w.cfg.sync == SyncBatch {
+		w.sync()
+	}
+}
+
+func (w *WAL[K, V]) sync() {
+	if !w.dirty {
+		return
+	}
+
+	if err := w.f.Sync(); err != nil {
+		w.fail(err)
+
+		return
+	}
+
+	w.dirty = false
+	w.lastSync = time.Now()
+}
+
+// run writes the batches that did not fill up in time, and syncs for SyncPeriodic.
+func (w *WAL[K, V]) run() {
+	defer close(w.done)
+
+	t := time.NewTicker(w.cfg.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.mu.Lock()
+
+			w.writeBatch()
+
+			if w.cfg.sync == SyncPeriodic && time.Since(w.lastSync) >= time.Second {
+				w.sync()
+			}
+
+			w.mu.Unlock()
+		}
+	}
+}
+
+// compact writes the sieve to a new snapshot and starts a new log, it runs under the lock of the sieve.
+func (w *WAL[K, V]) compact() error {
+	w.writeBatch()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	gen := w.gen + 1
+
+	payload, err := w.snapshot(gen)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(w.dir, snapshotName+".tmp")
+
+	if err := writeFile(tmp, appendFrame(nil, payload)); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(w.dir, snapshotName)); err != nil {
+		return err
+	}
+
+	f, err := openFile(w.logPath(gen), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if err := syncDir(w.dir); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	w.f.Close()
+
+	if err := os.Remove(w.logPath(w.gen)); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	w.f = f
+	w.gen = gen
+	w.size = 0
+	w.dirty = false
+
+	return nil
+}
+
+// snapshot encodes the nodes from the tail with the position of the hand.
+func (w *WAL[K, V]) snapshot(gen uint64) ([]byte, error) {
+	b := binary.AppendUvarint(nil, gen)
+	b = binary.AppendUvarint(b, uint64(w.s.Len()))
+
+	hand := int64(-1)
+	i := int64(0)
+
+	for n := w.s.tail; n != nil; n = n.prev {
+		if n == w.s.hand {
+			hand = i
+		}
+
+		i++
+	}
+
+	b = binary.AppendVarint(b, hand)
+
+	// the generations of the namespaces, before the nodes that refer to them
+	b = binary.AppendUvarint(b, uint64(len(w.s.namespaces)))
+
+	for _, ns := range w.s.namespaces {
+		b = appendGeneration(b, ns)
+	}
+
+	for n := w.s.tail; n != nil; n = n.prev {
+		var err error
+
+		if b, err = w.appendRecord(b, recSet, n.ns(), n, time.Time{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// Compact writes the sieve to a snapshot and starts a new log, as it happens when the log grows past WithCompactAt.
+func (w *WAL[K, V]) Compact() error {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	w.compactDue = false
+
+	if err := w.compact(); err != nil {
+		w.fail(err)
+	}
+
+	return w.err
+}
+
+// Flush writes and syncs the pending records, and returns the first error of the log if any.
+func (w *WAL[K, V]) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeBatch()
+	w.sync()
+
+	return w.err
+}
+
+// Close stops logging the changes of the sieve, then writes and syncs the pending records.
+func (w *WAL[K, V]) Close() error {
+	w.s.mu.Lock()
+	w.s.journal = nil
+	w.s.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeBatch()
+	w.sync()
+
+	return errors.Join(w.err, w.f.Close())
+}
+
+// openFile and readDir are the calls to the file system that cannot fail on a healthy disk.
+// They are variables to make it easier to fail them in tests.
+var (
+	openFile = os.OpenFile
+	readDir  = os.ReadDir
+)
+
+func appendTime(b []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return binary.AppendVarint(b, 0)
+	}
+
+	return binary.AppendVarint(b, t.UnixNano())
+}
+
+// appendNamespace encodes the name of ns with its length plus one, zero when ns is nil.
+func appendNamespace[K comparable, V any](b []byte, ns *namespace[K, V]) []byte {
+	if ns == nil {
+		return binary.AppendUvarint(b, 0)
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(ns.name))+1)
+
+	return append(b, ns.name...)
+}
+
+// appendGeneration encodes the recNamespace record of the generation of ns.
+func appendGeneration[K comparable, V any](b []byte, ns *namespace[K, V]) []byte {
+	b = append(b, recNamespace)
+	b = appendNamespace(b, ns)
+
+	return binary.AppendUvarint(b, ns.gen)
+}
+
+// appendFrame appends the checksum, the length and the payload.
+func appendFrame(b, payload []byte) []byte {
+	var header [frameHeader]byte
+
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+
+	crc := crc32.Update(crc32.Checksum(header[4:], castagnoli), castagnoli, payload)
+	binary.LittleEndian.PutUint32(header[:4], crc)
+
+	b = append(b, header[:]...)
+
+	return append(b, payload...)
+}
+
+var errChecksum = errors.New("checksum mismatch")
+
+// readFrame returns the payload of the next frame, io.EOF at the end,
+// io.ErrUnexpectedEOF for a torn frame and errChecksum for a corrupted one.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	var header [frameHeader]byte
+
+	if n, err := io.ReadFull(r, header[:]); err != nil {
+		if n == 0 && errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	size := binary.LittleEndian.Uint32(header[4:])
+	if size > maxFrame {
+		return nil, errChecksum
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	crc := crc32.Update(crc32.Checksum(header[4:], castagnoli), castagnoli, payload)
+	if crc != binary.LittleEndian.Uint32(header[:4]) {
+		return nil, errChecksum
+	}
+
+	return payload, nil
+}
+
+// writeFile writes and syncs a new file.
+func writeFile(name string, data []byte) error {
+	f, err := openFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	return errors.Join(f.Sync(), f.Close())
+}
+
+// syncDir syncs a directory, so that the files created or renamed in it survive a crash.
+func syncDir(dir string) error {
+	f, err := openFile(dir, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(f.Sync(), f.Close())
+}
+
+// decoder reads the fields of the records, the first error sticks.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+var errShort = errors.New("record too short")
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.b) == 0 {
+		d.err = errShort
+
+		return 0
+	}
+
+	c := d.b[0]
+	d.b = d.b[1:]
+
+	return c
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errShort
+
+		return 0
+	}
+
+	d.b = d.b[n:]
+
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errShort
+
+		return 0
+	}
+
+	d.b = d.b[n:]
+
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	size := d.uvarint()
+
+	if d.err != nil || uint64(len(d.b)) < size {
+		d.err = errShort
+
+		return nil
+	}
+
+	b := d.b[:size]
+	d.b = d.b[size:]
+
+	return b
+}
+
+// namespace reads the name of a namespace, ok is false for the keys of the sieve itself.
+func (d *decoder) namespace() (string, bool) {
+	size := d.uvarint()
+	if size == 0 {
+		return "", false
+	}
+
+	if d.err != nil || uint64(len(d.b)) < size-1 {
+		d.err = errShort
+
+		return "", false
+	}
+
+	name := string(d.b[:size-1])
+	d.b = d.b[size-1:]
+
+	return name, true
+}
+
+func (d *decoder) time() time.Time {
+	ns := d.varint()
+	if ns == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,861 @@
+package sieve
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dump describes the sieve from the tail, with the visited bits and the hand, to compare two sieves.
+func dump(s *Cache[int, int]) string {
+	var b strings.Builder
+
+	for n := s.tail; n != nil; n = n.prev {
+		if n == s.hand {
+			b.WriteString("*")
+		}
+
+		fmt.Fprintf(&b, "%d=%d", n.key, n.value)
+
+		if n.visited {
+			b.WriteString("v")
+		}
+
+		if n.absent {
+			b.WriteString("a")
+		}
+
+		if at := n.expiresAt(); !at.IsZero() {
+			fmt.Fprintf(&b, "@%d", at.UnixNano())
+		}
+
+		if ns := n.ns(); ns != nil {
+			fmt.Fprintf(&b, "[%s/%d]", ns.name, n.extra.gen)
+		}
+
+		b.WriteString(" ")
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.namespaces)) {
+		fmt.Fprintf(&b, "%s/%d ", name, s.namespaces[name].gen)
+	}
+
+	return b.String()
+}
+
+func openWAL(t *testing.T, s *Cache[int, int], dir string, opts ...WALOption) *WAL[int, int] {
+	t.Helper()
+
+	w, err := OpenWAL(s, dir, JSONCodec[int]{}, JSONCodec[int]{}, opts...)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	return w
+}
+
+// restored opens a new sieve from dir and returns it with its log.
+func restored(t *testing.T, dir string, opts ...WALOption) (*Cache[int, int], *WAL[int, int]) {
+	t.Helper()
+
+	s := New[int, int](8)
+
+	return s, openWAL(t, s, dir, opts...)
+}
+
+// randomOps applies random operations, with Get often enough that the hand has visited nodes to skip.
+func randomOps(s *Cache[int, int], rnd *rand.Rand, count int) {
+	for i := range count {
+		key := rnd.IntN(20)
+
+		switch rnd.IntN(12) {
+		case 0, 1, 2:
+			s.Set(key, i)
+		case 3, 4, 5, 6:
+			s.Get(key)
+		case 7:
+			s.Delete(key)
+		case 8:
+			s.SetWithTTL(key, i, time.Hour)
+		case 9:
+			s.SetNotFound(key)
+		case 10:
+			s.Namespace("ns").Set(key, i)
+		case 11:
+			// the same keys as the sieve, in their own namespace
+			if key == 0 {
+				s.Namespace("ns").Flush()
+			} else {
+				s.Namespace("ns").Get(key)
+			}
+		}
+	}
+}
+
+func TestWALRestore(t *testing.T) {
+	for _, opts := range [][]WALOption{
+		nil,
+		{WithBatch(1, time.Millisecond)},
+		{WithCompactAt(256)},
+		{WithSyncPolicy(SyncNone), WithBatch(1000, time.Hour)},
+		{WithSyncPolicy(SyncPeriodic), WithCompactAt(1024)},
+	} {
+		dir := t.TempDir()
+		rnd := rand.New(rand.NewPCG(1, 2))
+
+		s, w := restored(t, dir, opts...)
+
+		// several runs, each starting from the sieve restored by the previous one
+		for run := range 5 {
+			randomOps(s, rnd, 500)
+
+			expected := dump(s)
+
+			if err := w.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			s, w = restored(t, dir, opts...)
+
+			if got := dump(s); got != expected {
+				t.Fatalf("run %d: expected\n%s\ngot\n%s", run, expected, got)
+			}
+
+			if err := s.Validate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		w.Close()
+	}
+}
+
+func TestWALCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	s, w := restored(t, dir, WithBatch(1000, time.Hour))
+	defer w.Close()
+
+	randomOps(s, rand.New(rand.NewPCG(3, 4)), 200)
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := dump(s)
+
+	// the records after the flush are still in the batch, lost in the crash
+	s.Set(100, 100)
+
+	// no Close, the process died
+	crashed, w2 := restored(t, dir)
+	defer w2.Close()
+
+	if got := dump(crashed); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestWALCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	s, w := restored(t, dir, WithBatch(1, time.Hour), WithCompactAt(512))
+
+	for i := range 1000 {
+		s.Set(i%4, i)
+	}
+
+	expected := dump(s)
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(dir, logPrefix+"*"))
+	if len(logs) != 1 {
+		t.Errorf("expected one log after the compactions, got %v", logs)
+	}
+
+	info, err := os.Stat(logs[0])
+	if err != nil || info.Size() > 1024 {
+		t.Errorf("expected a small log, got %v, %v", info.Size(), err)
+	}
+
+	s, w = restored(t, dir)
+	defer w.Close()
+
+	if got := dump(s); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestWALTornBatch(t *testing.T) {
+	dir := t.TempDir()
+
+	s, w := restored(t, dir, WithBatch(1, time.Hour))
+	s.Set(1, 1)
+	s.Set(2, 2)
+	w.Close()
+
+	logs, _ := filepath.Glob(filepath.Join(dir, logPrefix+"*"))
+
+	info, _ := os.Stat(logs[0])
+
+	f, err := os.OpenFile(logs[0], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the crash happened in the middle of the batch of the next Set
+	frame := appendFrame(nil, []byte{recSet, 1, '3'})
+	_, _ = f.Write(frame[:len(frame)-1])
+	f.Close()
+
+	s, w = restored(t, dir)
+
+	if got := dump(s); got != "*1=1 2=2 " {
+		t.Errorf("expected 1 and 2, got %s", got)
+	}
+
+	if after, _ := os.Stat(logs[0]); after.Size() != info.Size() {
+		t.Errorf("expected the torn batch to be truncated, got size %d instead of %d", after.Size(), info.Size())
+	}
+
+	s.Set(3, 3)
+	w.Close()
+
+	s, w = restored(t, dir)
+	defer w.Close()
+
+	if got := dump(s); got != "*1=1 2=2 3=3 " {
+		t.Errorf("expected 1, 2 and 3, got %s", got)
+	}
+}
+
+func TestWALCorruptedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	s, w := restored(t, dir)
+	s.Set(1, 1)
+
+	if err := w.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+
+	name := filepath.Join(dir, snapshotName)
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(data)-1] ^= 0xff
+
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenWAL(New[int, int](8), dir, JSONCodec[int]{}, JSONCodec[int]{}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestWALFlushAndHand(t *testing.T) {
+	dir := t.TempDir()
+
+	s, w := restored(t, dir)
+
+	for i := range 8 {
+		s.Set(i, i)
+	}
+
+	s.Flush()
+
+	// 0 and 1 are visited, the hand clears them and evicts 2, then stops on 3
+	for i := range 8 {
+		s.Set(i, i)
+	}
+
+	s.Get(0)
+	s.Get(1)
+	s.Set(8, 8)
+
+	expected := dump(s)
+	if !strings.Contains(expected, "*3=3") {
+		t.Fatalf("expected the hand on 3, got %s", expected)
+	}
+
+	w.Close()
+
+	s, w = restored(t, dir)
+	defer w.Close()
+
+	if got := dump(s); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestWALOptions(t *testing.T) {
+	for _, opt := range []WALOption{
+		WithSyncPolicy(SyncPolicy(10)),
+		WithBatch(0, time.Second),
+		WithCompactAt(0),
+	} {
+		if _, err := OpenWAL(New[int, int](8), t.TempDir(), JSONCodec[int]{}, JSONCodec[int]{}, opt); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig, got %v", err)
+		}
+	}
+}
+
+// failingCodec fails to marshal or unmarshal the value bad.
+type failingCodec struct {
+	bad int
+}
+
+var errCodec = errors.New("codec failed")
+
+func (c failingCodec) Marshal(v int) ([]byte, error) {
+	if v == c.bad {
+		return nil, errCodec
+	}
+
+	return JSONCodec[int]{}.Marshal(v)
+}
+
+func (c failingCodec) Unmarshal(data []byte) (int, error) {
+	v, err := JSONCodec[int]{}.Unmarshal(data)
+	if err == nil && v == c.bad {
+		return 0, errCodec
+	}
+
+	return v, err
+}
+
+// writeLog writes a log of generation gen made of the frames of payloads.
+func writeLog(t *testing.T, dir string, gen uint64, frames ...[]byte) {
+	t.Helper()
+
+	var b []byte
+	for _, payload := range frames {
+		b = appendFrame(b, payload)
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, gen, logExt))
+	if err := os.WriteFile(name, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWALMarshalError(t *testing.T) {
+	s := New[int, int](8)
+
+	w, err := OpenWAL(s, t.TempDir(), JSONCodec[int]{}, failingCodec{bad: 13}, WithBatch(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Set(1, 1)
+	s.Set(2, 13)
+
+	// the log is unusable after the first error
+	s.Set(3, 3)
+
+	if err := w.Flush(); !errors.Is(err, errCodec) {
+		t.Errorf("expected the codec error, got %v", err)
+	}
+
+	if err := w.Compact(); !errors.Is(err, errCodec) {
+		t.Errorf("expected the codec error from Compact, got %v", err)
+	}
+
+	if err := w.Close(); !errors.Is(err, errCodec) {
+		t.Errorf("expected the codec error from Close, got %v", err)
+	}
+}
+
+func TestWALSnapshotMarshalError(t *testing.T) {
+	s := New[int, int](8)
+
+	w, err := OpenWAL(s, t.TempDir(), JSONCodec[int]{}, failingCodec{bad: 13}, WithBatch(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	s.Namespace("ns").Set(1, 1)
+	s.Set(1, 1)
+
+	// a value the codec only sees when the snapshot encodes it
+	s.m[1].value = 13
+
+	if err := w.Compact(); !errors.Is(err, errCodec) {
+		t.Errorf("expected the codec error, got %v", err)
+	}
+}
+
+func TestWALReplayErrors(t *testing.T) {
+	ok := JSONCodec[int]{}
+	bad := failingCodec{bad: 13}
+
+	set := func(key, value string) []byte {
+		b := []byte{recSet, 0, byte(len(key))}
+		b = append(b, key...)
+		b = append(b, byte(len(value)))
+		b = append(b, value...)
+
+		return append(b, 0, 0, 0, 0)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		payload  []byte
+		keys     Codec[int]
+		values   Codec[int]
+		expected error
+	}{
+		{"unknown record", []byte{99}, ok, ok, ErrCorrupted},
+		{"short set", []byte{recSet, 0, 1}, ok, ok, ErrCorrupted},
+		{"short visit", []byte{recVisit, 0, 5, '1'}, ok, ok, ErrCorrupted},
+		{"short namespace", []byte{recRemove, 5, 'n'}, ok, ok, ErrCorrupted},
+		{"short generation", []byte{recNamespace, 3, 'n', 's'}, ok, ok, ErrCorrupted},
+		{"set key", set("13", "1"), bad, ok, errCodec},
+		{"set value", set("1", "13"), ok, bad, errCodec},
+		{"visit key", []byte{recVisit, 0, 2, '1', '3', 0}, bad, ok, errCodec},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLog(t, dir, 0, set("1", "1"), tt.payload)
+
+			if _, err := OpenWAL(New[int, int](8), dir, tt.keys, tt.values); !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestWALCorruptedLog(t *testing.T) {
+	dir := t.TempDir()
+
+	frame := appendFrame(nil, []byte{recFlush})
+	frame[0] ^= 0xff
+
+	// only the last log can end with a torn batch, a bad one before it is corrupted
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, 0, logExt)), frame, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	writeLog(t, dir, 1, []byte{recFlush})
+
+	if _, err := OpenWAL(New[int, int](8), dir, JSONCodec[int]{}, JSONCodec[int]{}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted, got %v", err)
+	}
+
+	// a length past the limit is garbage too
+	var header [frameHeader]byte
+
+	binary.LittleEndian.PutUint32(header[4:], maxFrame+1)
+
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, 0, logExt)), header[:], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenWAL(New[int, int](8), dir, JSONCodec[int]{}, JSONCodec[int]{}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestWALSnapshotErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		payload []byte
+	}{
+		// gen, count, hand, namespaces, then the records
+		{"set instead of namespace", []byte{0, 0, 1, 1, recSet}},
+		{"namespace instead of set", []byte{0, 1, 1, 0, recNamespace}},
+		{"short set", []byte{0, 1, 1, 0, recSet, 0}},
+		{"short header", []byte{0, 0}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if err := os.WriteFile(filepath.Join(dir, snapshotName), appendFrame(nil, tt.payload), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := OpenWAL(New[int, int](8), dir, JSONCodec[int]{}, JSONCodec[int]{}); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("expected ErrCorrupted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWALStaleLog(t *testing.T) {
+	dir := t.TempDir()
+
+	s, w := restored(t, dir)
+	s.Set(1, 1)
+
+	if err := w.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+
+	// a crash after the snapshot was written, before the older log was removed
+	writeLog(t, dir, 0, []byte{recFlush})
+
+	// not logs of the WAL
+	for _, name := range []string{logPrefix + "notes" + logExt, logPrefix + "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, w = restored(t, dir)
+	defer w.Close()
+
+	if got := dump(s); got != "*1=1 " {
+		t.Errorf("expected the stale log to be skipped, got %s", got)
+	}
+
+	if gens, _ := w.logGens(); len(gens) != 1 {
+		t.Errorf("expected the stale log to be removed, got %v", gens)
+	}
+}
+
+func TestWALFileErrors(t *testing.T) {
+	t.Run("write", func(t *testing.T) {
+		s, w := restored(t, t.TempDir(), WithBatch(1000, time.Hour))
+
+		w.f.Close()
+		s.Set(1, 1)
+
+		if err := w.Flush(); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("expected os.ErrClosed, got %v", err)
+		}
+
+		if err := w.Close(); err == nil {
+			t.Errorf("expected an error from Close")
+		}
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		s, w := restored(t, t.TempDir(), WithSyncPolicy(SyncNone), WithBatch(1, time.Hour))
+
+		s.Set(1, 1)
+		w.f.Close()
+
+		if err := w.Flush(); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("expected os.ErrClosed, got %v", err)
+		}
+	})
+
+	t.Run("stale log", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, w := restored(t, dir)
+		s.Set(1, 1)
+
+		if err := w.Compact(); err != nil {
+			t.Fatal(err)
+		}
+
+		w.Close()
+
+		// a stale log that cannot be removed
+		if err := os.MkdirAll(filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, 0, logExt), "file"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := OpenWAL(New[int, int](8), dir, JSONCodec[int]{}, JSONCodec[int]{}); err == nil {
+			t.Errorf("expected an error for a stale log that cannot be removed")
+		}
+	})
+
+	t.Run("mkdir", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := OpenWAL(New[int, int](8), filepath.Join(file, "wal"), JSONCodec[int]{}, JSONCodec[int]{}); err == nil {
+			t.Errorf("expected an error for a directory under a file")
+		}
+	})
+
+	t.Run("log", func(t *testing.T) {
+		dir := t.TempDir()
+
+		if err := os.Mkdir(filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, 0, logExt)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := OpenWAL(New[int, int](8), dir, JSONCodec[int]{}, JSONCodec[int]{}); err == nil {
+			t.Errorf("expected an error for a log that is a directory")
+		}
+	})
+}
+
+func TestWALCompactErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		setup func(dir string) error
+	}{
+		{"temporary snapshot", func(dir string) error {
+			return os.Mkdir(filepath.Join(dir, snapshotName+".tmp"), 0o755)
+		}},
+		{"snapshot", func(dir string) error {
+			return os.MkdirAll(filepath.Join(dir, snapshotName, "file"), 0o755)
+		}},
+		{"next log", func(dir string) error {
+			return os.Mkdir(filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, 1, logExt)), 0o755)
+		}},
+		{"previous log", func(dir string) error {
+			return os.Remove(filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, 0, logExt)))
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// the log is due for a compaction after each record
+			s, w := restored(t, dir, WithBatch(1, time.Hour), WithCompactAt(1))
+			s.Set(1, 1)
+
+			if err := tt.setup(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			s.Set(2, 2)
+
+			if err := w.Flush(); err == nil {
+				t.Errorf("expected the compaction to fail")
+			}
+
+			w.Close()
+		})
+	}
+}
+
+// withOpenFile replaces openFile with open until the end of the test.
+func withOpenFile(t *testing.T, open func(name string, flag int, perm os.FileMode) (*os.File, error)) {
+	t.Helper()
+
+	openFile = open
+
+	t.Cleanup(func() { openFile = os.OpenFile })
+}
+
+var errDisk = errors.New("disk failed")
+
+func TestWALDiskErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		open func(name string, flag int, perm os.FileMode) (*os.File, error)
+	}{
+		{"snapshot", func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			if filepath.Base(name) == snapshotName {
+				return nil, errDisk
+			}
+
+			return os.OpenFile(name, flag, perm)
+		}},
+		{"log", func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			if flag&os.O_APPEND != 0 {
+				return nil, errDisk
+			}
+
+			return os.OpenFile(name, flag, perm)
+		}},
+		{"log size", func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			f, err := os.OpenFile(name, flag, perm)
+
+			// a closed file cannot tell its size
+			if err == nil && flag&os.O_APPEND != 0 {
+				f.Close()
+			}
+
+			return f, err
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			withOpenFile(t, tt.open)
+
+			if _, err := OpenWAL(New[int, int](8), t.TempDir(), JSONCodec[int]{}, JSONCodec[int]{}); err == nil {
+				t.Errorf("expected OpenWAL to fail")
+			}
+		})
+	}
+
+	t.Run("read dir", func(t *testing.T) {
+		readDir = func(string) ([]os.DirEntry, error) { return nil, errDisk }
+
+		t.Cleanup(func() { readDir = os.ReadDir })
+
+		if _, err := OpenWAL(New[int, int](8), t.TempDir(), JSONCodec[int]{}, JSONCodec[int]{}); !errors.Is(err, errDisk) {
+			t.Errorf("expected the disk error, got %v", err)
+		}
+	})
+}
+
+func TestWALCompactDiskErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		open func(dir string) func(name string, flag int, perm os.FileMode) (*os.File, error)
+	}{
+		{"sync dir", func(dir string) func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			return func(name string, flag int, perm os.FileMode) (*os.File, error) {
+				if name == dir {
+					return nil, errDisk
+				}
+
+				return os.OpenFile(name, flag, perm)
+			}
+		}},
+		{"write snapshot", func(string) func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			return func(name string, flag int, perm os.FileMode) (*os.File, error) {
+				// a file opened only for reading cannot be written
+				if strings.HasSuffix(name, ".tmp") {
+					return os.OpenFile(name, os.O_RDONLY|os.O_CREATE, perm)
+				}
+
+				return os.OpenFile(name, flag, perm)
+			}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			s, w := restored(t, dir)
+			defer w.Close()
+
+			s.Set(1, 1)
+
+			withOpenFile(t, tt.open(dir))
+
+			if err := w.Compact(); err == nil {
+				t.Errorf("expected the compaction to fail")
+			}
+		})
+	}
+}
+
+func TestWALReplayMissingKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	// a set of 5, then a visit and an eviction of keys the sieve does not have
+	writeLog(t, dir, 0,
+		[]byte{recSet, 0, 1, '5', 1, '5', 0, 0, 0, 0},
+		[]byte{recVisit, 0, 1, '5', 0},
+		[]byte{recEvict, 0, 1, '7', 0},
+	)
+
+	// go around the pin limit, so that there is no room for 5
+	s := New[int, int](2)
+	s.Set(1, 1)
+	s.Set(2, 2)
+
+	for _, n := range s.m {
+		n.pinned = true
+		s.pinned.Add(1)
+	}
+
+	w := openWAL(t, s, dir)
+	defer w.Close()
+
+	if s.Contains(5) || s.Len() != 2 {
+		t.Errorf("expected 5 to be dropped, got %s", dump(s))
+	}
+}
+
+func TestWALKeyMarshalError(t *testing.T) {
+	s := New[int, int](8)
+
+	w, err := OpenWAL(s, t.TempDir(), failingCodec{bad: 13}, JSONCodec[int]{}, WithBatch(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Set(13, 1)
+
+	if err := w.Close(); !errors.Is(err, errCodec) {
+		t.Errorf("expected the codec error, got %v", err)
+	}
+}
+
+func TestWALPeriodicSync(t *testing.T) {
+	s, w := restored(t, t.TempDir(), WithSyncPolicy(SyncPeriodic), WithBatch(1000, time.Millisecond))
+	defer w.Close()
+
+	// the last sync was long ago, so the next tick syncs
+	w.mu.Lock()
+	w.lastSync = time.Time{}
+	w.mu.Unlock()
+
+	s.Set(1, 1)
+
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		w.mu.Lock()
+		synced := !w.lastSync.IsZero()
+		w.mu.Unlock()
+
+		if synced {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected a periodic sync")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWALCompactWriteError(t *testing.T) {
+	s, w := restored(t, t.TempDir(), WithBatch(1000, time.Hour))
+
+	s.Set(1, 1)
+	w.f.Close()
+
+	// the pending batch is written first, and fails
+	if err := w.Compact(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected os.ErrClosed, got %v", err)
+	}
+
+	w.Close()
+}
+
+func TestWALTornHeader(t *testing.T) {
+	dir := t.TempDir()
+
+	writeLog(t, dir, 0, []byte{recSet, 0, 1, '1', 1, '1', 0, 0, 0, 0})
+
+	name := filepath.Join(dir, fmt.Sprintf("%s%016x%s", logPrefix, 0, logExt))
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the crash happened in the middle of the header of the next batch
+	_, _ = f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	s, w := restored(t, dir)
+	defer w.Close()
+
+	if got := dump(s); got != "*1=1 " {
+		t.Errorf("expected 1, got %s", got)
+	}
+}